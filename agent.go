@@ -0,0 +1,225 @@
+package llmstreamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/alparslanyilmaaz/llmstreamer/jsonschema"
+)
+
+const defaultMaxIterations = 10
+
+var ErrMaxIterations = errors.New("llmstreamer: agent reached max iterations")
+
+// ToolHandler runs a tool with the JSON arguments produced by the model. The
+// returned string is sent back to the model as the tool result; an error is
+// reported to the model as a failed result rather than aborting the run.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// TypedHandler adapts a function taking typed arguments to a ToolHandler. The
+// arguments are validated against the JSON schema of T before fn is called, so
+// malformed calls are reported back to the model.
+func TypedHandler[T any](fn func(ctx context.Context, args T) (string, error)) ToolHandler {
+	return func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		args, err := jsonschema.Decode[T](arguments)
+		if err != nil {
+			return "", err
+		}
+		return fn(ctx, args)
+	}
+}
+
+// Agent runs the tool loop on top of a Streamer: it streams a reply, executes
+// the tool calls it contains, appends the results and streams again until the
+// model answers without calling a tool.
+//
+// Content, tool call, usage and stats callbacks are forwarded for every turn;
+// OnFinish is only called once, with the final answer.
+type Agent struct {
+	Streamer      Streamer
+	MaxIterations int
+	Parallel      bool
+
+	// OnToolResult, if set, is called after each tool runs. With Parallel set
+	// it may be called concurrently.
+	OnToolResult func(call ToolCall, result string, err error)
+
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+var _ Streamer = (*Agent)(nil)
+
+func NewAgent(s Streamer) *Agent {
+	return &Agent{
+		Streamer:      s,
+		MaxIterations: defaultMaxIterations,
+		handlers:      make(map[string]ToolHandler),
+	}
+}
+
+// Handle registers a tool and the Go function that executes it. Registering a
+// tool with the same name again replaces the previous handler.
+func (a *Agent) Handle(tool Tool, handler ToolHandler) {
+	if a.handlers == nil {
+		a.handlers = make(map[string]ToolHandler)
+	}
+	if _, ok := a.handlers[tool.Name]; ok {
+		for i, t := range a.tools {
+			if t.Name == tool.Name {
+				a.tools[i] = tool
+			}
+		}
+	} else {
+		a.tools = append(a.tools, tool)
+	}
+	a.handlers[tool.Name] = handler
+}
+
+func (a *Agent) StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) {
+	_, err := a.Run(ctx, messages, cb, opts...)
+
+	var te *turnError
+	if err != nil && !errors.As(err, &te) {
+		cb.EmitError(err)
+	}
+}
+
+// Run executes the loop and returns the conversation including every
+// assistant turn and tool result. Errors from the underlying stream are
+// reported through OnError as they happen and also returned.
+func (a *Agent) Run(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) ([]Message, error) {
+	if a.Streamer == nil {
+		return nil, errors.New("llmstreamer: agent has no streamer")
+	}
+
+	maxIterations := a.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+
+	history := append([]Message(nil), messages...)
+	opts = append([]Option{WithTools(a.tools...)}, opts...)
+
+	for i := 0; i < maxIterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return history, err
+		}
+
+		result, err := a.turn(ctx, history, cb, opts)
+		if err != nil {
+			return history, &turnError{err: err}
+		}
+
+		history = append(history, Message{Role: RoleAssistant, Content: result.Message, ToolCalls: result.ToolCalls})
+		if len(result.ToolCalls) == 0 {
+			cb.EmitFinishResult(result)
+			return history, nil
+		}
+
+		history = append(history, a.execute(ctx, result.ToolCalls)...)
+	}
+
+	return history, ErrMaxIterations
+}
+
+func (a *Agent) turn(ctx context.Context, messages []Message, cb *StreamCallbacks, opts []Option) (FinishResult, error) {
+	var (
+		result   FinishResult
+		calls    []ToolCall
+		finished bool
+		lastErr  error
+	)
+
+	inner := &StreamCallbacks{
+		OnContent:       cb.EmitContent,
+		OnReasoning:     cb.EmitReasoning,
+		OnToolCallStart: cb.EmitToolCallStart,
+		OnToolCallDelta: cb.EmitToolCallDelta,
+		OnUsage:         cb.EmitUsage,
+		OnStats:         cb.EmitStats,
+		OnToolCall: func(call ToolCall) {
+			calls = append(calls, call)
+			cb.EmitToolCall(call)
+		},
+		OnFinishResult: func(r FinishResult) {
+			result = r
+		},
+		OnFinish: func(finalMessage string) {
+			result.Message = finalMessage
+			finished = true
+		},
+		OnError: func(err error) {
+			lastErr = err
+			cb.EmitError(err)
+		},
+	}
+
+	a.Streamer.StreamChat(ctx, messages, inner, opts...)
+
+	if !finished {
+		if lastErr == nil {
+			lastErr = errors.New("llmstreamer: stream ended without finishing")
+		}
+		return FinishResult{}, lastErr
+	}
+	result.ToolCalls = calls
+	return result, nil
+}
+
+func (a *Agent) execute(ctx context.Context, calls []ToolCall) []Message {
+	results := make([]Message, len(calls))
+
+	if !a.Parallel {
+		for i, call := range calls {
+			results[i] = a.call(ctx, call)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call ToolCall) {
+			defer wg.Done()
+			results[i] = a.call(ctx, call)
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+func (a *Agent) call(ctx context.Context, call ToolCall) Message {
+	var (
+		result string
+		err    error
+	)
+
+	handler, ok := a.handlers[call.Name]
+	if ok {
+		result, err = handler(ctx, json.RawMessage(call.Arguments))
+	} else {
+		err = fmt.Errorf("unknown tool %q", call.Name)
+	}
+
+	if a.OnToolResult != nil {
+		a.OnToolResult(call, result, err)
+	}
+
+	if err != nil {
+		return ToolResult(call.ID, "error: "+err.Error())
+	}
+	return ToolResult(call.ID, result)
+}
+
+// turnError marks errors that came from the underlying stream and were
+// therefore already delivered through OnError.
+type turnError struct {
+	err error
+}
+
+func (e *turnError) Error() string { return e.err.Error() }
+func (e *turnError) Unwrap() error { return e.err }
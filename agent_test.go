@@ -0,0 +1,290 @@
+package llmstreamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type scriptedTurn struct {
+	content string
+	calls   []ToolCall
+	err     error
+}
+
+// scriptedStreamer replays one turn per StreamChat call and records the
+// messages and options it received.
+type scriptedStreamer struct {
+	turns    []scriptedTurn
+	requests [][]Message
+	options  []Options
+}
+
+func (s *scriptedStreamer) StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) {
+	ctx, cb = TrackStats(ctx, cb)
+	s.requests = append(s.requests, append([]Message(nil), messages...))
+	s.options = append(s.options, NewOptions(opts...))
+
+	turn := s.turns[len(s.requests)-1]
+	if turn.err != nil {
+		cb.EmitError(turn.err)
+		return
+	}
+	if turn.content != "" {
+		cb.EmitContent(turn.content)
+	}
+	for _, c := range turn.calls {
+		cb.EmitToolCallStart(ToolCall{ID: c.ID, Name: c.Name})
+		cb.EmitToolCall(c)
+	}
+	cb.EmitFinishResult(FinishResult{Message: turn.content})
+}
+
+var addTool = Tool{Name: "add", Parameters: json.RawMessage(`{"type":"object"}`)}
+
+func addHandler(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct{ A, B int }
+	if err := json.Unmarshal(args, &in); err != nil {
+		return "", err
+	}
+	b, _ := json.Marshal(in.A + in.B)
+	return string(b), nil
+}
+
+func TestAgentRun_ExecutesToolsUntilAnswer(t *testing.T) {
+	s := &scriptedStreamer{turns: []scriptedTurn{
+		{content: "Let me add.", calls: []ToolCall{{ID: "c1", Name: "add", Arguments: `{"A":1,"B":2}`}}},
+		{content: "The answer is 3."},
+	}}
+
+	a := NewAgent(s)
+	a.Handle(addTool, addHandler)
+
+	var contents []string
+	var finals []string
+	history, err := a.Run(context.Background(), []Message{{Role: RoleUser, Content: "1+2?"}}, &StreamCallbacks{
+		OnContent: func(c string) { contents = append(contents, c) },
+		OnFinish:  func(f string) { finals = append(finals, f) },
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if len(contents) != 2 {
+		t.Fatalf("expected content from both turns, got %v", contents)
+	}
+	if len(finals) != 1 || finals[0] != "The answer is 3." {
+		t.Fatalf("expected a single OnFinish with the final answer, got %v", finals)
+	}
+
+	if len(history) != 4 {
+		t.Fatalf("expected user, assistant, tool, assistant messages, got %+v", history)
+	}
+	if len(history[1].ToolCalls) != 1 || history[1].ToolCalls[0].ID != "c1" {
+		t.Fatalf("assistant message should carry tool calls: %+v", history[1])
+	}
+	if history[2].Role != RoleTool || history[2].ToolCallID != "c1" || history[2].Content != "3" {
+		t.Fatalf("unexpected tool result: %+v", history[2])
+	}
+
+	second := s.requests[1]
+	if len(second) != 3 || second[2].Role != RoleTool {
+		t.Fatalf("second request should include the tool result: %+v", second)
+	}
+	if len(s.options[0].Tools) != 1 || s.options[0].Tools[0].Name != "add" {
+		t.Fatalf("registered tools should be sent: %+v", s.options[0].Tools)
+	}
+}
+
+func TestAgentRun_ToolErrorsAreReturnedToModel(t *testing.T) {
+	s := &scriptedStreamer{turns: []scriptedTurn{
+		{calls: []ToolCall{
+			{ID: "c1", Name: "fail", Arguments: `{}`},
+			{ID: "c2", Name: "missing", Arguments: `{}`},
+		}},
+		{content: "done"},
+	}}
+
+	a := NewAgent(s)
+	a.Handle(Tool{Name: "fail"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "", errors.New("disk full")
+	})
+
+	var observed []error
+	a.OnToolResult = func(call ToolCall, result string, err error) { observed = append(observed, err) }
+
+	history, err := a.Run(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if history[1].Content != "error: disk full" {
+		t.Fatalf("unexpected failed tool result: %q", history[1].Content)
+	}
+	if history[2].Content != `error: unknown tool "missing"` {
+		t.Fatalf("unexpected unknown tool result: %q", history[2].Content)
+	}
+	if len(observed) != 2 || observed[0] == nil || observed[1] == nil {
+		t.Fatalf("expected OnToolResult to observe both errors, got %v", observed)
+	}
+}
+
+func TestAgentRun_MaxIterations(t *testing.T) {
+	loop := scriptedTurn{calls: []ToolCall{{ID: "c", Name: "add", Arguments: `{}`}}}
+	s := &scriptedStreamer{turns: []scriptedTurn{loop, loop, loop}}
+
+	a := NewAgent(s)
+	a.MaxIterations = 2
+	a.Handle(addTool, addHandler)
+
+	var gotErr error
+	a.StreamChat(context.Background(), nil, &StreamCallbacks{
+		OnError:  func(err error) { gotErr = err },
+		OnFinish: func(string) { t.Fatalf("unexpected finish") },
+	})
+
+	if !errors.Is(gotErr, ErrMaxIterations) {
+		t.Fatalf("expected ErrMaxIterations, got %v", gotErr)
+	}
+	if len(s.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(s.requests))
+	}
+}
+
+func TestAgentStreamChat_StreamErrorReportedOnce(t *testing.T) {
+	want := errors.New("boom")
+	s := &scriptedStreamer{turns: []scriptedTurn{{err: want}}}
+
+	var errs []error
+	NewAgent(s).StreamChat(context.Background(), nil, &StreamCallbacks{
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+
+	if len(errs) != 1 || !errors.Is(errs[0], want) {
+		t.Fatalf("expected the stream error exactly once, got %v", errs)
+	}
+
+	_, err := NewAgent(&scriptedStreamer{turns: []scriptedTurn{{err: want}}}).Run(context.Background(), nil, nil)
+	if !errors.Is(err, want) {
+		t.Fatalf("expected Run to return the stream error, got %v", err)
+	}
+}
+
+func TestAgentRun_Parallel(t *testing.T) {
+	s := &scriptedStreamer{turns: []scriptedTurn{
+		{calls: []ToolCall{
+			{ID: "c1", Name: "slow", Arguments: `{"n":1}`},
+			{ID: "c2", Name: "slow", Arguments: `{"n":2}`},
+		}},
+		{content: "done"},
+	}}
+
+	var running, peak int32
+	a := NewAgent(s)
+	a.Parallel = true
+	a.Handle(Tool{Name: "slow"}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return string(args), nil
+	})
+
+	history, err := a.Run(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if atomic.LoadInt32(&peak) != 2 {
+		t.Fatalf("expected tools to run concurrently, peak was %d", peak)
+	}
+	if history[1].ToolCallID != "c1" || history[2].ToolCallID != "c2" || history[2].Content != `{"n":2}` {
+		t.Fatalf("results must keep call order: %+v", history[1:3])
+	}
+}
+
+func TestAgentHandle_ReplacesTool(t *testing.T) {
+	a := NewAgent(nil)
+	a.Handle(Tool{Name: "x", Description: "old"}, addHandler)
+	a.Handle(Tool{Name: "x", Description: "new"}, addHandler)
+
+	if len(a.tools) != 1 || a.tools[0].Description != "new" {
+		t.Fatalf("expected tool to be replaced, got %+v", a.tools)
+	}
+	if _, err := a.Run(context.Background(), nil, nil); err == nil {
+		t.Fatalf("expected error for agent without streamer")
+	}
+}
+
+func TestTypedHandler(t *testing.T) {
+	type args struct {
+		City string `json:"city" jsonschema:"required"`
+	}
+
+	h := TypedHandler(func(ctx context.Context, a args) (string, error) {
+		return "sunny in " + a.City, nil
+	})
+
+	got, err := h(context.Background(), json.RawMessage(`{"city":"Paris"}`))
+	if err != nil || got != "sunny in Paris" {
+		t.Fatalf("unexpected result %q, %v", got, err)
+	}
+
+	if _, err := h(context.Background(), json.RawMessage(`{}`)); err == nil {
+		t.Fatalf("expected validation error for missing city")
+	}
+}
+
+func TestAgentRun_FinishResultOnlyForFinalTurn(t *testing.T) {
+	s := &scriptedStreamer{turns: []scriptedTurn{
+		{calls: []ToolCall{{ID: "c1", Name: "add", Arguments: `{"A":1,"B":1}`}}},
+		{content: "2"},
+	}}
+
+	a := NewAgent(s)
+	a.Handle(addTool, addHandler)
+
+	var results []FinishResult
+	if _, err := a.Run(context.Background(), nil, &StreamCallbacks{
+		OnFinishResult: func(r FinishResult) { results = append(results, r) },
+	}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Message != "2" || len(results[0].ToolCalls) != 0 {
+		t.Fatalf("expected a single final result, got %+v", results)
+	}
+}
+
+func TestAgentRun_StatsForEveryTurn(t *testing.T) {
+	s := &scriptedStreamer{turns: []scriptedTurn{
+		{calls: []ToolCall{{ID: "c1", Name: "add", Arguments: `{"A":1,"B":1}`}}},
+		{content: "2"},
+	}}
+
+	a := NewAgent(s)
+	a.Handle(addTool, addHandler)
+
+	var stats []StreamStats
+	var result FinishResult
+	a.StreamChat(context.Background(), nil, &StreamCallbacks{
+		OnStats:        func(st StreamStats) { stats = append(stats, st) },
+		OnFinishResult: func(r FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if len(stats) != 2 || stats[1].Deltas != 1 {
+		t.Fatalf("expected stats for both turns, got %+v", stats)
+	}
+	if result.Stats == nil || *result.Stats != stats[1] {
+		t.Fatalf("final result stats = %+v, want %+v", result.Stats, stats[1])
+	}
+}
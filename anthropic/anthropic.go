@@ -1,7 +1,6 @@
 package anthropic
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,13 +8,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/sse"
 )
 
 type AnthropicStreamer struct {
 	ApiKey string
 	Model  Model
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces DefaultBaseURL, for proxies and local stand-ins.
+	BaseURL string
+	// Version is sent as the anthropic-version header. Empty means
+	// DefaultVersion.
+	Version string
+	// Header is added to every request, for example anthropic-beta. It
+	// overrides the headers set by the streamer.
+	Header http.Header
 }
 
 func New(apiKey string, model Model) *AnthropicStreamer {
@@ -25,18 +45,40 @@ func New(apiKey string, model Model) *AnthropicStreamer {
 	}
 }
 
-const url = "https://api.anthropic.com/v1/messages"
+var (
+	_ llmstreamer.Streamer = (*AnthropicStreamer)(nil)
+	_ llmstreamer.Warmer   = (*AnthropicStreamer)(nil)
+)
+
+func init() {
+	llmstreamer.Register("anthropic", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		s := New(cfg.APIKey, Model(cfg.Model))
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		s.Version = cfg.APIVersion
+		return s, nil
+	})
+}
+
+const (
+	DefaultBaseURL = "https://api.anthropic.com/v1"
+	DefaultVersion = "2023-06-01"
+)
 
 func (s *AnthropicStreamer) StreamChat(
 	ctx context.Context,
 	messages []llmstreamer.Message,
 	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
 ) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
 	if s.ApiKey == "" {
-		if cb != nil && cb.OnError != nil {
-			err := errors.New("invalid apiKey")
-			cb.OnError(err)
-		}
+		cb.EmitError(errors.New("invalid apiKey"))
 		return
 	}
 
@@ -45,22 +87,102 @@ func (s *AnthropicStreamer) StreamChat(
 		model = ModelClaude3Opus
 	}
 
-	payload := RequestBody{
-		Model:     model,
-		Messages:  messages,
-		MaxTokens: 1024,
-		Stream:    true,
+	o := llmstreamer.NewOptions(opts...)
+	payload, err := NewRequestBody(model, messages, o)
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, payload, ResponseFormatCallbacks(o, cb))
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *AnthropicStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *AnthropicStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint())
+}
+
+func (s *AnthropicStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+// ResponseFormatCallbacks wraps cb for a request built from o. If o has a
+// response format, the input of the forced response tool is delivered as
+// regular content, so callers see the JSON answer the same way as with
+// providers that support a JSON response mode; the other callbacks are
+// passed through. Otherwise cb is returned unchanged. Each request needs its
+// own wrapper.
+func ResponseFormatCallbacks(o llmstreamer.Options, cb *llmstreamer.StreamCallbacks) *llmstreamer.StreamCallbacks {
+	if o.ResponseFormat == nil || cb == nil {
+		return cb
+	}
+
+	tool := o.ResponseFormat.Name
+	var id string
+	var answer strings.Builder
+
+	wrapped := *cb
+	wrapped.OnToolCallStart = func(call llmstreamer.ToolCall) {
+		if call.Name == tool && id == "" {
+			id = call.ID
+			return
+		}
+		cb.EmitToolCallStart(call)
 	}
+	wrapped.OnToolCallDelta = func(callID, delta string) {
+		if callID == id {
+			answer.WriteString(delta)
+			cb.EmitContent(delta)
+			return
+		}
+		cb.EmitToolCallDelta(callID, delta)
+	}
+	wrapped.OnToolCall = func(call llmstreamer.ToolCall) {
+		if call.ID != id {
+			cb.EmitToolCall(call)
+		}
+	}
+	// OnFinish is called by cb with the rewritten message.
+	wrapped.OnFinish = nil
+	wrapped.OnFinishResult = func(result llmstreamer.FinishResult) {
+		if id != "" {
+			result.Message = answer.String()
+			if result.StopReason == llmstreamer.StopReasonToolUse {
+				result.StopReason = llmstreamer.StopReasonEndTurn
+			}
 
-	if err := streamAnthropic(ctx, payload, s.ApiKey, cb); err != nil {
-		if cb != nil && cb.OnError != nil {
-			cb.OnError(err)
+			var calls []llmstreamer.ToolCall
+			for _, call := range result.ToolCalls {
+				if call.ID != id {
+					calls = append(calls, call)
+				}
+			}
+			result.ToolCalls = calls
 		}
+		cb.EmitFinishResult(result)
 	}
+	return &wrapped
 }
 
-func streamAnthropic(ctx context.Context, payload RequestBody, apiKey string, cb *llmstreamer.StreamCallbacks) error {
-	client, req, err := prepareRequest(ctx, payload, apiKey)
+func (s *AnthropicStreamer) stream(ctx context.Context, payload RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, payload)
 
 	if err != nil {
 		return err
@@ -76,91 +198,191 @@ func streamAnthropic(ctx context.Context, payload RequestBody, apiKey string, cb
 	}
 
 	defer resp.Body.Close()
-	processStream(resp, cb)
-	return nil
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
 }
 
-func prepareRequest(ctx context.Context, payload RequestBody, apiKey string) (*http.Client, *http.Request, error) {
+func (s *AnthropicStreamer) prepareRequest(ctx context.Context, payload RequestBody) (*http.Client, *http.Request, error) {
 	data, err := json.Marshal(payload)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader(data))
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	req.Header.Set("x-api-key", apiKey)
+	version := s.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	req.Header.Set("x-api-key", s.ApiKey)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("anthropic-version", version)
+	llmstreamer.SetHeaders(req.Header, s.Header)
 
-	client := &http.Client{
-		Timeout: 0,
-	}
+	client := s.client()
 
 	return client, req, nil
 }
 
-func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) {
+func (s *AnthropicStreamer) endpoint() string {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/messages"
+}
+
+// processStream delivers the response to cb. Errors that end the stream are
+// returned rather than emitted so the caller can decide whether to retry.
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
 	if resp.StatusCode != http.StatusOK {
 		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
 		if err != nil {
-			cb.OnError(fmt.Errorf("non-200: %d, read body failed: %w", resp.StatusCode, err))
-			return
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
 		}
-		cb.OnError(fmt.Errorf("non-200: %d, body: %s", resp.StatusCode, string(b)))
-		return
+		return apiErr
+	}
+
+	dec := sse.NewDecoder(resp.Body)
+	// Proxies may close the stream without the blank line after the last
+	// event.
+	dec.DispatchAtEOF = true
+	defer dec.Release()
+	next := func() ([]byte, error) {
+		ev, err := dec.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("read failed: %w", err)
+		}
+		return ev.Data, nil
 	}
 
-	reader := bufio.NewReader(resp.Body)
+	err := ProcessEvents(next, cb)
+
+	var apiErr *llmstreamer.APIError
+	if errors.As(err, &apiErr) && apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("request-id")
+	}
+	return err
+}
+
+// toolUse is a tool_use block whose input is still streaming.
+type toolUse struct {
+	call llmstreamer.ToolCall
+	args strings.Builder
+}
 
-	var finalMessage string
+// ProcessEvents delivers a stream of Messages API events to cb. next returns
+// the JSON of each event in turn and io.EOF at the end of the stream; it lets
+// platforms that carry the same events in a different framing, such as
+// Bedrock, share the event handling. Errors that end the stream are returned
+// rather than emitted.
+func ProcessEvents(next func() ([]byte, error), cb *llmstreamer.StreamCallbacks) error {
+	var (
+		finalMessage strings.Builder
+		result       llmstreamer.FinishResult
+		usage        *Usage
+		events       eventDecoder
+	)
+	tools := make(map[int]*toolUse)
+
+	finish := func() {
+		if usage != nil {
+			u := usage.toUsage(result.Model)
+			result.Usage = &u
+			cb.EmitUsage(u)
+		}
+		result.Message = finalMessage.String()
+		cb.EmitFinishResult(result)
+	}
 
 	for {
-		line, err := reader.ReadBytes('\n')
+		data, err := next()
 		if err != nil {
 			if err == io.EOF {
-				cb.OnFinish(finalMessage)
-				return
+				finish()
+				return nil
 			}
-			cb.OnError(fmt.Errorf("read failed: %w", err))
-			return
+			return err
 		}
 
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
+		ev, err := events.decode(data)
+		if err != nil {
+			cb.EmitError(fmt.Errorf("failed to parse JSON: %w", err))
 			continue
 		}
 
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			data := line[len("data: "):]
-
-			var ev StreamEvent
-			if err := json.Unmarshal(data, &ev); err != nil {
-				cb.OnError(fmt.Errorf("failed to parse JSON: %w", err))
-				continue
+		switch ev.Type {
+		case Start:
+			if ev.Message != nil {
+				result.ResponseID = ev.Message.ID
+				result.Model = ev.Message.Model
+				if ev.Message.Usage != nil {
+					usage = &Usage{}
+					usage.merge(*ev.Message.Usage)
+				}
 			}
-
-			switch ev.Type {
-			case Delta:
-				if ev.Delta != nil && ev.Delta.Text != "" {
-					if cb != nil && cb.OnContent != nil {
-						finalMessage += ev.Delta.Text
-						cb.OnContent(ev.Delta.Text)
-					}
+		case MessageDelta:
+			if ev.Delta != nil && ev.Delta.StopReason != "" {
+				result.RawStopReason = ev.Delta.StopReason
+				result.StopReason = stopReason(ev.Delta.StopReason)
+				result.StopSequence = ev.Delta.StopSequence
+			}
+			if ev.Usage != nil {
+				if usage == nil {
+					usage = &Usage{}
 				}
-			case Finish:
-				if cb != nil && cb.OnFinish != nil {
-					cb.OnFinish(finalMessage)
-					return
+				usage.merge(*ev.Usage)
+			}
+		case ContentStart:
+			if ev.ContentBlock != nil && ev.ContentBlock.Type == "tool_use" {
+				tool := &toolUse{call: llmstreamer.ToolCall{ID: ev.ContentBlock.ID, Name: ev.ContentBlock.Name}}
+				tools[ev.Index] = tool
+				cb.EmitToolCallStart(tool.call)
+			}
+		case Delta:
+			if ev.Delta == nil {
+				continue
+			}
+			if ev.Delta.Text != "" {
+				finalMessage.WriteString(ev.Delta.Text)
+				cb.EmitContent(ev.Delta.Text)
+			}
+			if tool, ok := tools[ev.Index]; ok && ev.Delta.PartialJSON != "" {
+				tool.args.WriteString(ev.Delta.PartialJSON)
+				cb.EmitToolCallDelta(tool.call.ID, ev.Delta.PartialJSON)
+			}
+		case Stop:
+			if tool, ok := tools[ev.Index]; ok {
+				call := tool.call
+				call.Arguments = tool.args.String()
+				if call.Arguments == "" {
+					call.Arguments = "{}"
 				}
-			default:
-				// Ignore other event types for now
-				// fmt.Printf("[unknown type: %s]\n", ev.Type)
+				cb.EmitToolCall(call)
+				result.ToolCalls = append(result.ToolCalls, call)
+				delete(tools, ev.Index)
+			}
+		case Finish:
+			finish()
+			return nil
+		case Error:
+			if ev.Error == nil {
+				ev.Error = &ErrorDetail{Type: "api_error", Message: "unknown stream error"}
 			}
+			return newStreamError(ev.Error)
+		default:
+			// Ignore other event types for now
+			// fmt.Printf("[unknown type: %s]\n", ev.Type)
 		}
 	}
 }
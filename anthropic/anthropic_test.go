@@ -6,8 +6,10 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/alparslanyilmaaz/llmstreamer"
 )
@@ -56,8 +58,8 @@ func TestStreamChat_DefaultModel(t *testing.T) {
 	defer func() { http.DefaultTransport = orig }()
 
 	body := "" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"ok\"}}\n" +
-		"data: {\"type\":\"message_stop\"}\n"
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"ok\"}}\n\n" +
+		"data: {\"type\":\"message_stop\"}\n\n"
 
 	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 		b, _ := io.ReadAll(req.Body)
@@ -110,7 +112,7 @@ func TestStreamChat_TransportError(t *testing.T) {
 func TestStreamAnthropic_Success(t *testing.T) {
 	payload := RequestBody{
 		Model:     ModelClaude3Opus,
-		Messages:  []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
+		Messages:  []Message{{Role: llmstreamer.RoleUser, Content: []ContentBlock{{Type: "text", Text: "hello"}}}},
 		MaxTokens: 5,
 		Stream:    true,
 	}
@@ -121,9 +123,9 @@ func TestStreamAnthropic_Success(t *testing.T) {
 	defer func() { http.DefaultTransport = origTransport }()
 
 	body := "" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" there\"}}\n" +
-		"data: {\"type\":\"message_stop\"}\n"
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" there\"}}\n\n" +
+		"data: {\"type\":\"message_stop\"}\n\n"
 
 	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 		if req.Method != http.MethodPost {
@@ -143,9 +145,9 @@ func TestStreamAnthropic_Success(t *testing.T) {
 		OnError:   func(err error) { t.Fatalf("unexpected OnError: %v", err) },
 	}
 
-	err := streamAnthropic(context.Background(), payload, apiKey, cb)
+	err := New(apiKey, "").stream(context.Background(), payload, cb)
 	if err != nil {
-		t.Fatalf("streamAnthropic returned error: %v", err)
+		t.Fatalf("stream returned error: %v", err)
 	}
 	if !called {
 		t.Fatalf("expected at least one callback to be called")
@@ -155,13 +157,13 @@ func TestStreamAnthropic_Success(t *testing.T) {
 func TestPrepareRequest_Success(t *testing.T) {
 	payload := RequestBody{
 		Model:     ModelClaude3Opus,
-		Messages:  []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
+		Messages:  []Message{{Role: llmstreamer.RoleUser, Content: []ContentBlock{{Type: "text", Text: "hello"}}}},
 		MaxTokens: 5,
 		Stream:    true,
 	}
 
 	apiKey := "test-key"
-	client, req, err := prepareRequest(context.Background(), payload, apiKey)
+	client, req, err := New(apiKey, "").prepareRequest(context.Background(), payload)
 	if err != nil {
 		t.Fatalf("prepareRequest returned error: %v", err)
 	}
@@ -175,8 +177,8 @@ func TestPrepareRequest_Success(t *testing.T) {
 	if req.Method != http.MethodPost {
 		t.Fatalf("expected POST method, got %s", req.Method)
 	}
-	if req.URL == nil || req.URL.String() != url {
-		t.Fatalf("expected URL %s, got %v", url, req.URL)
+	if req.URL == nil || req.URL.String() != DefaultBaseURL+"/messages" {
+		t.Fatalf("expected URL %s/messages, got %v", DefaultBaseURL, req.URL)
 	}
 
 	if got := req.Header.Get("x-api-key"); got != apiKey {
@@ -208,16 +210,16 @@ func TestPrepareRequest_Success(t *testing.T) {
 	if got.Model != payload.Model {
 		t.Fatalf("model mismatch: expected %v got %v", payload.Model, got.Model)
 	}
-	if len(got.Messages) != len(payload.Messages) || got.Messages[0].Content != payload.Messages[0].Content {
+	if len(got.Messages) != len(payload.Messages) || got.Messages[0].Content[0].Text != payload.Messages[0].Content[0].Text {
 		t.Fatalf("messages mismatch: expected %+v got %+v", payload.Messages, got.Messages)
 	}
 }
 
 func TestProcessStream_DeltaFinish(t *testing.T) {
 	body := "" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hello\"}}\n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" world\"}}\n" +
-		"data: {\"type\":\"message_stop\"}\n"
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hello\"}}\n\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" world\"}}\n\n" +
+		"data: {\"type\":\"message_stop\"}\n\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -258,14 +260,7 @@ func TestProcessStream_Non200(t *testing.T) {
 		Body:       io.NopCloser(strings.NewReader("bad request")),
 	}
 
-	var gotErr error
-	cb := &llmstreamer.StreamCallbacks{
-		OnError: func(err error) {
-			gotErr = err
-		},
-	}
-
-	processStream(resp, cb)
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
 
 	if gotErr == nil {
 		t.Fatalf("expected an error for non-200 response")
@@ -281,15 +276,10 @@ func TestProcessStream_Non200ReadError(t *testing.T) {
 		Body:       errReadCloser{},
 	}
 
-	var gotErr error
-	cb := &llmstreamer.StreamCallbacks{
-		OnError: func(err error) { gotErr = err },
-	}
-
-	processStream(resp, cb)
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
 
 	if gotErr == nil {
-		t.Fatalf("expected OnError to be called when Read fails")
+		t.Fatalf("expected an error when Read fails")
 	}
 	if !strings.Contains(gotErr.Error(), "read body failed") {
 		t.Fatalf("expected error message to mention read body failure, got: %v", gotErr)
@@ -302,16 +292,14 @@ func TestProcessStream_ReadFailedInLoop(t *testing.T) {
 		Body:       errReadCloser{},
 	}
 
-	var gotErr error
 	cb := &llmstreamer.StreamCallbacks{
-		OnError:  func(err error) { gotErr = err },
 		OnFinish: func(s string) { t.Fatalf("unexpected finish: %q", s) },
 	}
 
-	processStream(resp, cb)
+	gotErr := processStream(resp, cb)
 
 	if gotErr == nil {
-		t.Fatalf("expected OnError when reader returns error during streaming")
+		t.Fatalf("expected an error when reader returns error during streaming")
 	}
 	if !strings.Contains(gotErr.Error(), "read failed") {
 		t.Fatalf("expected error message to contain 'read failed', got: %v", gotErr)
@@ -320,7 +308,7 @@ func TestProcessStream_ReadFailedInLoop(t *testing.T) {
 
 func TestProcessStream_EOFTriggersFinish(t *testing.T) {
 	body := "" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n\n" +
 		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" there\"}}\n"
 
 	resp := &http.Response{
@@ -344,9 +332,9 @@ func TestProcessStream_EOFTriggersFinish(t *testing.T) {
 
 func TestProcessStream_InvalidJSONThenValid(t *testing.T) {
 	body := "" +
-		"data: not-a-json\n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Ok\"}}\n" +
-		"data: {\"type\":\"message_stop\"}\n"
+		"data: not-a-json\n\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Ok\"}}\n\n" +
+		"data: {\"type\":\"message_stop\"}\n\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -390,9 +378,9 @@ func TestProcessStream_InvalidJSONThenValid(t *testing.T) {
 func TestProcessStream_IgnoreEmptyLines(t *testing.T) {
 	body := "" +
 		"\n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"A\"}}\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"A\"}}\n\n" +
 		"   \n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"B\"}}\n"
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"B\"}}\n\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -420,3 +408,712 @@ func TestProcessStream_IgnoreEmptyLines(t *testing.T) {
 		t.Fatalf("unexpected final message: %q", final)
 	}
 }
+
+func TestRegisteredProvider(t *testing.T) {
+	s, err := llmstreamer.Open("anthropic", llmstreamer.Config{APIKey: "k", Model: string(ModelClaude35Haiku)})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	a, ok := s.(*AnthropicStreamer)
+	if !ok {
+		t.Fatalf("expected *AnthropicStreamer, got %T", s)
+	}
+	if a.ApiKey != "k" || a.Model != ModelClaude35Haiku {
+		t.Fatalf("unexpected streamer config: %+v", a)
+	}
+}
+
+func TestRegisteredProvider_APIVersion(t *testing.T) {
+	var version string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = r.Header.Get("anthropic-version")
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer srv.Close()
+
+	s, err := llmstreamer.Open("anthropic", llmstreamer.Config{APIKey: "k", BaseURL: srv.URL, APIVersion: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+	if version != "2024-01-01" {
+		t.Fatalf("anthropic-version = %q, want 2024-01-01", version)
+	}
+}
+
+func TestNewRequestBody_Options(t *testing.T) {
+	o := llmstreamer.NewOptions(
+		llmstreamer.WithMaxTokens(64),
+		llmstreamer.WithTemperature(0.3),
+		llmstreamer.WithTopP(0.8),
+		llmstreamer.WithTopK(5),
+		llmstreamer.WithStop("END"),
+	)
+
+	p, err := NewRequestBody(ModelClaude35Haiku, nil, o)
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if got["max_tokens"] != float64(64) {
+		t.Fatalf("expected max_tokens 64, got %v", got["max_tokens"])
+	}
+	if got["temperature"] != 0.3 || got["top_p"] != 0.8 || got["top_k"] != float64(5) {
+		t.Fatalf("sampling params not mapped: %v", got)
+	}
+	if stops, ok := got["stop_sequences"].([]interface{}); !ok || len(stops) != 1 || stops[0] != "END" {
+		t.Fatalf("expected stop_sequences [END], got %v", got["stop_sequences"])
+	}
+}
+
+func TestNewRequestBody_DefaultMaxTokens(t *testing.T) {
+	p, err := NewRequestBody(ModelClaude35Haiku, nil, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+	if p.MaxTokens != defaultMaxTokens {
+		t.Fatalf("expected default max tokens %d, got %d", defaultMaxTokens, p.MaxTokens)
+	}
+
+	b, _ := json.Marshal(p)
+	if strings.Contains(string(b), "temperature") || strings.Contains(string(b), "top_k") {
+		t.Fatalf("unset options should be omitted: %s", b)
+	}
+}
+
+func TestNewRequestBody_UnsupportedOptions(t *testing.T) {
+	tests := []llmstreamer.Option{
+		llmstreamer.WithSeed(1),
+		llmstreamer.WithPresencePenalty(0.5),
+		llmstreamer.WithFrequencyPenalty(0.5),
+	}
+
+	for _, opt := range tests {
+		_, err := NewRequestBody(ModelClaude35Haiku, nil, llmstreamer.NewOptions(opt))
+		if !errors.Is(err, llmstreamer.ErrUnsupportedOption) {
+			t.Fatalf("expected ErrUnsupportedOption, got %v", err)
+		}
+	}
+
+	if _, err := NewRequestBody(ModelClaude35Haiku, nil, llmstreamer.NewOptions(llmstreamer.WithTemperature(1.5))); err == nil {
+		t.Fatalf("expected temperature above 1 to be rejected")
+	}
+}
+
+func TestStreamChat_InvalidOptionCallsOnError(t *testing.T) {
+	s := New("test-key", "")
+
+	var gotErr error
+	cb := &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	}
+
+	s.StreamChat(context.Background(), nil, cb, llmstreamer.WithSeed(42))
+
+	if !errors.Is(gotErr, llmstreamer.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, got %v", gotErr)
+	}
+}
+
+func TestNewRequestBody_SystemPrompt(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleSystem, Content: "You are terse."},
+		{Role: llmstreamer.RoleDeveloper, Content: "Answer in English."},
+		{Role: llmstreamer.RoleUser, Content: "hi"},
+		{Role: llmstreamer.RoleAssistant, Content: "hello"},
+	}
+
+	p, err := NewRequestBody(ModelClaude35Haiku, messages, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	if p.System != "You are terse.\n\nAnswer in English." {
+		t.Fatalf("unexpected system prompt: %q", p.System)
+	}
+	if len(p.Messages) != 2 || p.Messages[0].Role != llmstreamer.RoleUser || p.Messages[1].Role != llmstreamer.RoleAssistant {
+		t.Fatalf("instruction messages should be removed from messages: %+v", p.Messages)
+	}
+
+	b, _ := json.Marshal(p)
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got["system"] != p.System {
+		t.Fatalf("expected top-level system field, got %v", got["system"])
+	}
+}
+
+func TestNewRequestBody_NoSystemPromptOmitted(t *testing.T) {
+	p, err := NewRequestBody(ModelClaude35Haiku, []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	if strings.Contains(string(b), `"system"`) {
+		t.Fatalf("expected system to be omitted: %s", b)
+	}
+}
+
+func TestNewRequestBody_Tools(t *testing.T) {
+	o := llmstreamer.NewOptions(
+		llmstreamer.WithTools(llmstreamer.Tool{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}),
+		llmstreamer.WithForcedTool("get_weather"),
+	)
+
+	p, err := NewRequestBody(ModelClaude35Sonnet, nil, o)
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	if len(p.Tools) != 1 || p.Tools[0].Name != "get_weather" || !strings.Contains(string(p.Tools[0].InputSchema), "city") {
+		t.Fatalf("unexpected tools: %+v", p.Tools)
+	}
+	if p.ToolChoice == nil || p.ToolChoice.Type != "tool" || p.ToolChoice.Name != "get_weather" {
+		t.Fatalf("unexpected tool choice: %+v", p.ToolChoice)
+	}
+
+	p, _ = NewRequestBody(ModelClaude35Sonnet, nil, llmstreamer.NewOptions(llmstreamer.WithToolChoice(llmstreamer.ToolChoiceRequired)))
+	if p.ToolChoice == nil || p.ToolChoice.Type != "any" {
+		t.Fatalf("expected required to map to any, got %+v", p.ToolChoice)
+	}
+}
+
+func TestTranslateMessages_ToolRoundTrip(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleUser, Content: "Weather in Paris and Rome?"},
+		{
+			Role:    llmstreamer.RoleAssistant,
+			Content: "Checking.",
+			ToolCalls: []llmstreamer.ToolCall{
+				{ID: "toolu_1", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+				{ID: "toolu_2", Name: "get_weather", Arguments: `{"city":"Rome"}`},
+			},
+		},
+		llmstreamer.ToolResult("toolu_1", "sunny"),
+		llmstreamer.ToolResult("toolu_2", "rainy"),
+	}
+
+	got := translateMessages(messages)
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages after merging tool results, got %d: %+v", len(got), got)
+	}
+
+	assistant := got[1]
+	if assistant.Role != llmstreamer.RoleAssistant || len(assistant.Content) != 3 {
+		t.Fatalf("unexpected assistant message: %+v", assistant)
+	}
+	if assistant.Content[0].Type != "text" || assistant.Content[1].Type != "tool_use" || assistant.Content[1].ID != "toolu_1" {
+		t.Fatalf("unexpected assistant blocks: %+v", assistant.Content)
+	}
+	if string(assistant.Content[2].Input) != `{"city":"Rome"}` {
+		t.Fatalf("unexpected tool input: %s", assistant.Content[2].Input)
+	}
+
+	results := got[2]
+	if results.Role != llmstreamer.RoleUser || len(results.Content) != 2 {
+		t.Fatalf("expected tool results in a single user message: %+v", results)
+	}
+	if results.Content[0].Type != "tool_result" || results.Content[0].ToolUseID != "toolu_1" || results.Content[0].Content != "sunny" {
+		t.Fatalf("unexpected tool result block: %+v", results.Content[0])
+	}
+}
+
+func TestTranslateMessages_SkipsEmptyMessages(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleUser, Content: "Hi"},
+		{Role: llmstreamer.RoleAssistant},
+		{Role: llmstreamer.RoleUser, Content: "Are you there?"},
+	}
+
+	got := translateMessages(messages)
+
+	if len(got) != 1 || len(got[0].Content) != 2 {
+		t.Fatalf("expected one user message with two text blocks, got %+v", got)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if strings.Contains(string(b), "null") {
+		t.Fatalf("unexpected null content: %s", b)
+	}
+}
+
+func TestProcessStream_ToolUse(t *testing.T) {
+	body := "" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check."}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var starts []llmstreamer.ToolCall
+	var deltas []string
+	var calls []llmstreamer.ToolCall
+	var final string
+
+	cb := &llmstreamer.StreamCallbacks{
+		OnToolCallStart: func(c llmstreamer.ToolCall) { starts = append(starts, c) },
+		OnToolCallDelta: func(id, d string) {
+			if id != "toolu_1" {
+				t.Fatalf("unexpected delta id %q", id)
+			}
+			deltas = append(deltas, d)
+		},
+		OnToolCall: func(c llmstreamer.ToolCall) { calls = append(calls, c) },
+		OnFinish:   func(f string) { final = f },
+		OnError:    func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}
+
+	processStream(resp, cb)
+
+	if len(starts) != 1 || starts[0].ID != "toolu_1" || starts[0].Name != "get_weather" {
+		t.Fatalf("unexpected tool starts: %+v", starts)
+	}
+	if len(deltas) != 2 {
+		t.Fatalf("expected 2 argument deltas, got %v", deltas)
+	}
+	if len(calls) != 1 || calls[0].Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls: %+v", calls)
+	}
+	if final != "Let me check." {
+		t.Fatalf("unexpected final message: %q", final)
+	}
+}
+
+func TestProcessStream_ToolUseWithoutInput(t *testing.T) {
+	body := "" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"now","input":{}}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var calls []llmstreamer.ToolCall
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnToolCall: func(c llmstreamer.ToolCall) { calls = append(calls, c) },
+	})
+
+	if len(calls) != 1 || calls[0].Arguments != "{}" {
+		t.Fatalf("expected empty object arguments, got %+v", calls)
+	}
+}
+
+func TestNewRequestBody_ResponseFormatForcesTool(t *testing.T) {
+	rf := llmstreamer.ResponseFormat{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`)}
+
+	p, err := NewRequestBody(ModelClaude35Sonnet, nil, llmstreamer.NewOptions(llmstreamer.WithResponseFormat(rf)))
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+	if len(p.Tools) != 1 || p.Tools[0].Name != "answer" || string(p.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Fatalf("expected response tool, got %+v", p.Tools)
+	}
+	if p.ToolChoice == nil || p.ToolChoice.Type != "tool" || p.ToolChoice.Name != "answer" {
+		t.Fatalf("expected forced tool choice, got %+v", p.ToolChoice)
+	}
+
+	_, err = NewRequestBody(ModelClaude35Sonnet, nil, llmstreamer.NewOptions(
+		llmstreamer.WithResponseFormat(rf),
+		llmstreamer.WithToolChoice(llmstreamer.ToolChoiceAuto),
+	))
+	if err == nil {
+		t.Fatalf("expected error when combining response format and tool choice")
+	}
+}
+
+func TestResponseFormatCallbacks(t *testing.T) {
+	body := "" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"answer","input":{}}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"value\":"}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":" 42}"}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var contents []string
+	var final string
+	var result llmstreamer.FinishResult
+	o := llmstreamer.Options{ResponseFormat: &llmstreamer.ResponseFormat{Name: "answer"}}
+	cb := ResponseFormatCallbacks(o, &llmstreamer.StreamCallbacks{
+		OnContent:      func(s string) { contents = append(contents, s) },
+		OnToolCall:     func(c llmstreamer.ToolCall) { t.Fatalf("response tool must not surface as a tool call") },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnFinish:       func(f string) { final = f },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	processStream(resp, cb)
+
+	if strings.Join(contents, "") != `{"value": 42}` {
+		t.Fatalf("unexpected contents: %v", contents)
+	}
+	if final != `{"value": 42}` {
+		t.Fatalf("unexpected final message: %q", final)
+	}
+	if len(result.ToolCalls) != 0 || result.Message != final {
+		t.Fatalf("response tool must not appear in the finish result: %+v", result)
+	}
+}
+
+func TestResponseFormatCallbacks_PassesThrough(t *testing.T) {
+	body := "" +
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":20,"output_tokens":1}}}` + "\n\n" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"answer","input":{}}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"value\": 42}"}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var usage *llmstreamer.Usage
+	var reasoning, final string
+	var result llmstreamer.FinishResult
+	o := llmstreamer.Options{ResponseFormat: &llmstreamer.ResponseFormat{Name: "answer"}}
+	cb := ResponseFormatCallbacks(o, &llmstreamer.StreamCallbacks{
+		OnReasoning:    func(r string) { reasoning += r },
+		OnUsage:        func(u llmstreamer.Usage) { usage = &u },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnFinish:       func(f string) { final = f },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	cb.EmitReasoning("The user wants a number.")
+	processStream(resp, cb)
+
+	if usage == nil || usage.InputTokens != 20 || usage.OutputTokens != 9 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+	if reasoning != "The user wants a number." {
+		t.Fatalf("unexpected reasoning: %q", reasoning)
+	}
+	if final != `{"value": 42}` || result.Message != final || result.StopReason != llmstreamer.StopReasonEndTurn {
+		t.Fatalf("unexpected result: final %q, %+v", final, result)
+	}
+	if result.Usage == nil || result.Usage.OutputTokens != 9 {
+		t.Fatalf("unexpected result usage: %+v", result.Usage)
+	}
+}
+
+func TestProcessStream_Usage(t *testing.T) {
+	body := "" +
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":100,"output_tokens":1,"cache_creation_input_tokens":2000,"cache_read_input_tokens":5000}}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}` + "\n\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":400}}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var usages []llmstreamer.Usage
+	var order []string
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnUsage: func(u llmstreamer.Usage) {
+			usages = append(usages, u)
+			order = append(order, "usage")
+		},
+		OnFinish: func(string) { order = append(order, "finish") },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if len(usages) != 1 {
+		t.Fatalf("expected one usage report, got %d", len(usages))
+	}
+	u := usages[0]
+	if u.InputTokens != 100 || u.OutputTokens != 400 || u.CacheWriteTokens != 2000 || u.CacheReadTokens != 5000 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+
+	want := (100*3.00 + 400*15.00 + 2000*3.75 + 5000*0.30) / 1e6
+	if diff := u.Cost - want; diff > 1e-12 || diff < -1e-12 {
+		t.Fatalf("expected cost %v, got %v", want, u.Cost)
+	}
+	if len(order) != 2 || order[0] != "usage" || order[1] != "finish" {
+		t.Fatalf("expected usage before finish, got %v", order)
+	}
+}
+
+func TestProcessStream_NoUsageNotReported(t *testing.T) {
+	body := `data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnUsage: func(u llmstreamer.Usage) { t.Fatalf("unexpected usage: %+v", u) },
+	})
+}
+
+func TestPriceFor(t *testing.T) {
+	if p, ok := PriceFor("claude-3-5-haiku-20241022"); !ok || p != prices[ModelClaude35Haiku] {
+		t.Fatalf("expected exact match, got %+v %v", p, ok)
+	}
+	if p, ok := PriceFor("claude-3-5-sonnet-20241022-v2:0"); !ok || p != prices[ModelClaude35Sonnet] {
+		t.Fatalf("expected prefix match, got %+v %v", p, ok)
+	}
+	if _, ok := PriceFor("claude-unknown"); ok {
+		t.Fatalf("expected unknown model to have no price")
+	}
+}
+
+func TestSetPrice(t *testing.T) {
+	want := llmstreamer.Price{Input: 2.40, Output: 12.00}
+	old, _ := PriceFor(string(ModelClaude3Sonnet))
+	defer SetPrice(ModelClaude3Sonnet, old)
+
+	SetPrice(ModelClaude3Sonnet, want)
+	if p, ok := PriceFor("claude-3-sonnet-20240229"); !ok || p != want {
+		t.Fatalf("expected the negotiated price, got %+v %v", p, ok)
+	}
+}
+
+func TestProcessStream_FinishResult(t *testing.T) {
+	body := "" +
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-3-5-haiku-20241022","usage":{"input_tokens":10,"output_tokens":1}}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"One, two"}}` + "\n\n" +
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"count","input":{}}}` + "\n\n" +
+		`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"stop_sequence","stop_sequence":"three"},"usage":{"output_tokens":3}}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var result llmstreamer.FinishResult
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+	})
+
+	if result.Message != "One, two" {
+		t.Fatalf("unexpected message: %q", result.Message)
+	}
+	if result.StopReason != llmstreamer.StopReasonStopSequence || result.RawStopReason != "stop_sequence" || result.StopSequence != "three" {
+		t.Fatalf("unexpected stop details: %+v", result)
+	}
+	if result.ResponseID != "msg_1" || result.Model != "claude-3-5-haiku-20241022" {
+		t.Fatalf("unexpected metadata: %+v", result)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].ID != "toolu_1" {
+		t.Fatalf("unexpected tool calls: %+v", result.ToolCalls)
+	}
+	if result.Usage == nil || result.Usage.OutputTokens != 3 {
+		t.Fatalf("unexpected usage: %+v", result.Usage)
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	tests := map[string]llmstreamer.StopReason{
+		"end_turn":      llmstreamer.StopReasonEndTurn,
+		"max_tokens":    llmstreamer.StopReasonMaxTokens,
+		"stop_sequence": llmstreamer.StopReasonStopSequence,
+		"tool_use":      llmstreamer.StopReasonToolUse,
+		"refusal":       llmstreamer.StopReasonContentFilter,
+		"pause_turn":    llmstreamer.StopReasonOther,
+	}
+	for raw, want := range tests {
+		if got := stopReason(raw); got != want {
+			t.Fatalf("stopReason(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestProcessStream_APIError(t *testing.T) {
+	header := make(http.Header)
+	header.Set("request-id", "req_abc")
+
+	resp := &http.Response{
+		StatusCode: 529,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)),
+	}
+
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Provider != "anthropic" || apiErr.Type != "overloaded_error" || apiErr.Message != "Overloaded" || apiErr.RequestID != "req_abc" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if !errors.Is(gotErr, llmstreamer.ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded")
+	}
+}
+
+func TestNewAPIError_Kinds(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{401, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, llmstreamer.ErrAuth},
+		{429, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, llmstreamer.ErrRateLimited},
+		{400, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 250000 tokens > 200000 maximum"}}`, llmstreamer.ErrContextLength},
+	}
+
+	for _, tt := range tests {
+		err := newAPIError(&http.Response{StatusCode: tt.status}, []byte(tt.body))
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("expected %v for %s", tt.want, tt.body)
+		}
+	}
+}
+
+func TestStreamChat_RetriesOverloaded(t *testing.T) {
+	s := New("test-key", "")
+	s.Retry = &llmstreamer.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+
+	calls := 0
+	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: 529, Body: io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))}, nil
+		}
+		body := `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}` + "\n\n" +
+			`data: {"type":"message_stop"}` + "\n\n"
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	var attempts []llmstreamer.RetryAttempt
+	s.Retry.OnAttempt = func(a llmstreamer.RetryAttempt) { attempts = append(attempts, a) }
+
+	var final string
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if calls != 2 || final != "ok" {
+		t.Fatalf("calls = %d, final = %q", calls, final)
+	}
+	if len(attempts) != 1 || !errors.Is(attempts[0].Err, llmstreamer.ErrOverloaded) {
+		t.Fatalf("unexpected attempts: %+v", attempts)
+	}
+}
+
+func TestProcessStream_ErrorEvent(t *testing.T) {
+	header := make(http.Header)
+	header.Set("request-id", "req_456")
+
+	body := "event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}` + "\n\n" +
+		"event: error\n" +
+		`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n"
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+
+	var content string
+	err := processStream(resp, &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { content += c },
+		OnFinish:  func(f string) { t.Fatalf("unexpected finish: %q", f) },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.Type != "overloaded_error" || apiErr.Message != "Overloaded" || apiErr.RequestID != "req_456" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if !errors.Is(err, llmstreamer.ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded")
+	}
+	if content != "Hel" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+}
+
+func TestPrepareRequest_VersionAndHeaders(t *testing.T) {
+	client := &http.Client{}
+	s := New("test-key", "")
+	s.HTTPClient = client
+	s.BaseURL = "http://localhost:8080"
+	s.Version = "2024-01-01"
+	s.Header = http.Header{"Anthropic-Beta": {"prompt-caching-2024-07-31"}}
+
+	gotClient, req, err := s.prepareRequest(context.Background(), RequestBody{})
+	if err != nil {
+		t.Fatalf("prepareRequest returned error: %v", err)
+	}
+	if gotClient != client {
+		t.Fatalf("expected the configured client")
+	}
+	if req.URL.String() != "http://localhost:8080/messages" {
+		t.Fatalf("unexpected URL: %v", req.URL)
+	}
+	if got := req.Header.Get("anthropic-version"); got != "2024-01-01" {
+		t.Fatalf("unexpected anthropic-version: %q", got)
+	}
+	if got := req.Header.Get("anthropic-beta"); got != "prompt-caching-2024-07-31" {
+		t.Fatalf("unexpected anthropic-beta: %q", got)
+	}
+	if got := req.Header.Get("x-api-key"); got != "test-key" {
+		t.Fatalf("unexpected x-api-key: %q", got)
+	}
+}
+
+func TestStream_Text(t *testing.T) {
+	s := New("test-key", "")
+	s.HTTPClient = &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}` + "\n\n" +
+			`data: {"type":"message_stop"}` + "\n\n"
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	st, err := s.Stream(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := st.Text()
+	if err != nil || text != "Hello there" {
+		t.Fatalf("Text() = %q, %v", text, err)
+	}
+}
@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// longStream builds a response of n single-token text deltas, the shape of
+// almost every event in a long answer.
+func longStream(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("event: message_start\n" + `data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"stop_reason":null,"usage":{"input_tokens":25,"output_tokens":1}}}` + "\n\n")
+	b.WriteString("event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n")
+	for i := 0; i < n; i++ {
+		token := " token"
+		if i%20 == 19 {
+			token = `.\n`
+		}
+		fmt.Fprintf(&b, "event: content_block_delta\n"+`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"%s"}}`+"\n\n", token)
+	}
+	b.WriteString("event: content_block_stop\n" + `data: {"type":"content_block_stop","index":0}` + "\n\n")
+	b.WriteString("event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":100000}}` + "\n\n")
+	b.WriteString("event: message_stop\n" + `data: {"type":"message_stop"}` + "\n\n")
+	return b.Bytes()
+}
+
+func BenchmarkProcessStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	cb := &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { n += len(c) },
+		OnError:   func(err error) { b.Fatal(err) },
+	}
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(stream))}
+		if err := processStream(resp, cb); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLegacyStream_100kTokens decodes the same response the way streams
+// were read before the scanner: a bufio.Scanner over the lines and
+// encoding/json for every event. It is the baseline for the benchmark above.
+func BenchmarkLegacyStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var message strings.Builder
+		sc := bufio.NewScanner(bytes.NewReader(stream))
+		for sc.Scan() {
+			data, ok := bytes.CutPrefix(sc.Bytes(), []byte("data: "))
+			if !ok {
+				continue
+			}
+			var ev StreamEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				b.Fatal(err)
+			}
+			if ev.Type == Delta && ev.Delta != nil {
+				message.WriteString(ev.Delta.Text)
+				n += len(ev.Delta.Text)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
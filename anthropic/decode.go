@@ -0,0 +1,75 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"github.com/alparslanyilmaaz/llmstreamer/internal/jsonscan"
+)
+
+// eventDecoder decodes stream events. Almost every event of a response is a
+// content_block_delta, so that shape is read with a scanner that reuses the
+// previous event's strings; anything else goes through encoding/json. The
+// decoded event shares memory with the decoder and is only valid until the
+// next call.
+type eventDecoder struct {
+	scan  jsonscan.Scanner
+	delta DeltaData
+}
+
+func (d *eventDecoder) decode(data []byte) (StreamEvent, error) {
+	if ev, ok := d.decodeDelta(data); ok {
+		return ev, nil
+	}
+	var ev StreamEvent
+	err := json.Unmarshal(data, &ev)
+	return ev, err
+}
+
+// decodeDelta decodes data if it is an event with only a type, an index and a
+// text or JSON delta. It reports false, leaving the caller to use
+// encoding/json, for any other event.
+func (d *eventDecoder) decodeDelta(data []byte) (StreamEvent, bool) {
+	s := &d.scan
+	s.Reset(data)
+	prevType := d.delta.Type
+	var ev StreamEvent
+
+	ok := s.Object(func(key []byte) bool {
+		switch string(key) {
+		case "type":
+			t := string(ev.Type)
+			ok := s.ReadString(&t, string(Delta))
+			ev.Type = Type(t)
+			return ok
+		case "index":
+			if s.Null() {
+				return true
+			}
+			n, ok := s.Int()
+			ev.Index = int(n)
+			return ok
+		case "delta":
+			if s.Null() {
+				ev.Delta = nil
+				return true
+			}
+			if ev.Delta == nil {
+				d.delta = DeltaData{}
+				ev.Delta = &d.delta
+			}
+			return s.Object(func(key []byte) bool {
+				switch string(key) {
+				case "type":
+					return s.ReadString(&d.delta.Type, prevType)
+				case "text":
+					return s.ReadString(&d.delta.Text, "")
+				case "partial_json":
+					return s.ReadString(&d.delta.PartialJSON, "")
+				}
+				return false
+			})
+		}
+		return false
+	})
+	return ev, ok && s.End()
+}
@@ -0,0 +1,41 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEventDecoder(t *testing.T) {
+	events := []struct {
+		data string
+		fast bool
+	}{
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`, true},
+		{`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"location\": \"San Fra"}}`, true},
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"café 😀\n"}}`, true},
+		{`{"type":"content_block_stop","index":0}`, true},
+		{`{"type":"message_stop"}`, true},
+		{`{"type":"ping"}`, true},
+		// Everything else is left to encoding/json.
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`, false},
+		{`{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":15}}`, false},
+		{`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`, false},
+		{`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, false},
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"a"}`, false},
+	}
+
+	var d eventDecoder
+	for _, ev := range events {
+		if _, ok := d.decodeDelta([]byte(ev.data)); ok != ev.fast {
+			t.Errorf("decodeDelta(%s) = %v, want %v", ev.data, ok, ev.fast)
+		}
+
+		got, gotErr := d.decode([]byte(ev.data))
+		var want StreamEvent
+		wantErr := json.Unmarshal([]byte(ev.data), &want)
+		if (gotErr != nil) != (wantErr != nil) || !reflect.DeepEqual(got, want) {
+			t.Errorf("decode(%s):\n got %+v, %v\nwant %+v, %v", ev.data, got, gotErr, want, wantErr)
+		}
+	}
+}
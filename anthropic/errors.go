@@ -0,0 +1,48 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+type ErrorBody struct {
+	Type  string       `json:"type"`
+	Error *ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "anthropic",
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("request-id"),
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+	if e.RetryAfter == 0 && resp.StatusCode == http.StatusTooManyRequests {
+		e.RetryAfter = llmstreamer.ParseRateLimitReset(resp.Header)
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Error == nil {
+		e.Body = string(body)
+		return e
+	}
+	e.Type = eb.Error.Type
+	e.Message = eb.Error.Message
+	return e
+}
+
+// newStreamError converts an error event received after a 200 response.
+func newStreamError(d *ErrorDetail) *llmstreamer.APIError {
+	return &llmstreamer.APIError{
+		Provider: "anthropic",
+		Type:     d.Type,
+		Message:  d.Message,
+	}
+}
@@ -0,0 +1,59 @@
+package anthropic
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// prices holds the list prices used to compute Usage.Cost. It is read by
+// every stream, so changes go through SetPrice.
+var (
+	pricesMu sync.RWMutex
+	prices   = map[Model]llmstreamer.Price{
+		ModelClaude35Sonnet: {Input: 3.00, Output: 15.00, CacheRead: 0.30, CacheWrite: 3.75},
+		ModelClaude35Haiku:  {Input: 0.80, Output: 4.00, CacheRead: 0.08, CacheWrite: 1.00},
+
+		ModelClaude3Opus:   {Input: 15.00, Output: 75.00, CacheRead: 1.50, CacheWrite: 18.75},
+		ModelClaude3Sonnet: {Input: 3.00, Output: 15.00, CacheRead: 0.30, CacheWrite: 3.75},
+		ModelClaude3Haiku:  {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.30},
+
+		ModelClaude21: {Input: 8.00, Output: 24.00},
+		ModelClaude20: {Input: 8.00, Output: 24.00},
+
+		ModelClaudeInstant12: {Input: 0.80, Output: 2.40},
+		ModelClaudeInstant11: {Input: 0.80, Output: 2.40},
+	}
+)
+
+// PriceFor looks up the price of a model. Names that extend a known model,
+// such as "claude-3-5-sonnet-20241022-v2", match the longest model name they
+// start with.
+func PriceFor(model string) (llmstreamer.Price, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	if p, ok := prices[Model(model)]; ok {
+		return p, true
+	}
+
+	var best Model
+	for m := range prices {
+		if strings.HasPrefix(model, string(m)) && len(m) > len(best) {
+			best = m
+		}
+	}
+	if best == "" {
+		return llmstreamer.Price{}, false
+	}
+	return prices[best], true
+}
+
+// SetPrice adds or replaces the price of a model, for example to match
+// negotiated rates. It is safe to call while streams are running.
+func SetPrice(model Model, price llmstreamer.Price) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices[model] = price
+}
@@ -1,12 +1,230 @@
 package anthropic
 
-import "github.com/alparslanyilmaaz/llmstreamer"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+const defaultMaxTokens = 1024
 
 type RequestBody struct {
-	Model     Model                 `json:"model"`
-	Messages  []llmstreamer.Message `json:"messages"`
-	MaxTokens int                   `json:"max_tokens"`
-	Stream    bool                  `json:"stream"`
+	// Model is empty for platforms that take the model from the URL.
+	Model Model `json:"model,omitempty"`
+	// AnthropicVersion is set by platforms such as Bedrock and Vertex AI,
+	// which take the API version in the body instead of a header.
+	AnthropicVersion string `json:"anthropic_version,omitempty"`
+
+	System        string      `json:"system,omitempty"`
+	Messages      []Message   `json:"messages"`
+	MaxTokens     int         `json:"max_tokens"`
+	Temperature   *float64    `json:"temperature,omitempty"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	TopK          *int        `json:"top_k,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Tools         []Tool      `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice `json:"tool_choice,omitempty"`
+	Stream        bool        `json:"stream,omitempty"`
+}
+
+type Message struct {
+	Role    llmstreamer.Role `json:"role"`
+	Content []ContentBlock   `json:"content"`
+}
+
+type ContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// NewRequestBody builds the Messages API request for messages and o. It is
+// exported for the platforms that serve the same API.
+func NewRequestBody(model Model, messages []llmstreamer.Message, o llmstreamer.Options) (RequestBody, error) {
+	if err := validateOptions(o); err != nil {
+		return RequestBody{}, err
+	}
+
+	maxTokens := o.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	system, turns := splitSystem(messages)
+
+	body := RequestBody{
+		Model:         model,
+		System:        system,
+		Messages:      translateMessages(turns),
+		MaxTokens:     maxTokens,
+		Temperature:   o.Temperature,
+		TopP:          o.TopP,
+		TopK:          o.TopK,
+		StopSequences: o.Stop,
+		Tools:         translateTools(o.Tools),
+		ToolChoice:    translateToolChoice(o.ToolChoice),
+		Stream:        true,
+	}
+
+	// Anthropic has no JSON response mode, so a response format is sent as a
+	// tool the model is forced to call; its input is the structured answer.
+	if rf := o.ResponseFormat; rf != nil {
+		description := rf.Description
+		if description == "" {
+			description = "Respond with a JSON object matching this schema."
+		}
+		body.Tools = append(body.Tools, Tool{Name: rf.Name, Description: description, InputSchema: rf.Schema})
+		body.ToolChoice = &ToolChoice{Type: "tool", Name: rf.Name}
+	}
+
+	return body, nil
+}
+
+// splitSystem moves system and developer messages out of the conversation,
+// since Anthropic takes the system prompt as a top-level field. Multiple
+// instruction messages are joined in order.
+func splitSystem(messages []llmstreamer.Message) (string, []llmstreamer.Message) {
+	var system []string
+	turns := make([]llmstreamer.Message, 0, len(messages))
+
+	for _, m := range messages {
+		if m.IsInstruction() {
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		}
+		turns = append(turns, m)
+	}
+
+	return strings.Join(system, "\n\n"), turns
+}
+
+// translateMessages converts messages to content blocks. Tool results become
+// tool_result blocks in a user message, and consecutive messages with the same
+// role are merged because the API requires user and assistant turns to
+// alternate. Messages with neither content nor tool calls, such as an empty
+// assistant turn, are dropped, since the API rejects empty content.
+func translateMessages(messages []llmstreamer.Message) []Message {
+	out := make([]Message, 0, len(messages))
+
+	for _, m := range messages {
+		role := m.Role
+		var blocks []ContentBlock
+
+		switch m.Role {
+		case llmstreamer.RoleTool:
+			role = llmstreamer.RoleUser
+			blocks = append(blocks, ContentBlock{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   m.Content,
+			})
+		default:
+			if m.Content != "" {
+				blocks = append(blocks, ContentBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, ContentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: input,
+				})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			continue
+		}
+		out = append(out, Message{Role: role, Content: blocks})
+	}
+	return out
+}
+
+func translateTools(tools []llmstreamer.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]Tool, len(tools))
+	for i, t := range tools {
+		schema := t.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		out[i] = Tool{Name: t.Name, Description: t.Description, InputSchema: schema}
+	}
+	return out
+}
+
+func translateToolChoice(choice *llmstreamer.ToolChoice) *ToolChoice {
+	if choice == nil {
+		return nil
+	}
+
+	switch choice.Mode {
+	case llmstreamer.ToolChoiceRequired:
+		return &ToolChoice{Type: "any"}
+	case llmstreamer.ToolChoiceTool:
+		return &ToolChoice{Type: "tool", Name: choice.Name}
+	default:
+		return &ToolChoice{Type: string(choice.Mode)}
+	}
+}
+
+func validateOptions(o llmstreamer.Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case o.Seed != nil:
+		return llmstreamer.Unsupported("seed")
+	case o.PresencePenalty != nil:
+		return llmstreamer.Unsupported("presence_penalty")
+	case o.FrequencyPenalty != nil:
+		return llmstreamer.Unsupported("frequency_penalty")
+	}
+
+	if o.ResponseFormat != nil && o.ToolChoice != nil {
+		return errors.New("anthropic: response format cannot be combined with a tool choice")
+	}
+
+	if o.Temperature != nil && *o.Temperature > 1 {
+		return fmt.Errorf("anthropic: temperature must be between 0 and 1, got %v", *o.Temperature)
+	}
+	return nil
 }
 
 type Type string
@@ -16,16 +234,86 @@ const (
 	ContentStart Type = "content_block_start"
 	Delta        Type = "content_block_delta"
 	Stop         Type = "content_block_stop"
+	MessageDelta Type = "message_delta"
 	Finish       Type = "message_stop"
+	Error        Type = "error"
 )
 
 type StreamEvent struct {
-	Type  Type       `json:"type"`
-	Index int        `json:"index"`
-	Delta *DeltaData `json:"delta,omitempty"`
+	Type         Type          `json:"type"`
+	Index        int           `json:"index"`
+	Delta        *DeltaData    `json:"delta,omitempty"`
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+	Message      *MessageInfo  `json:"message,omitempty"`
+	Usage        *Usage        `json:"usage,omitempty"`
+	Error        *ErrorDetail  `json:"error,omitempty"`
+}
+
+type MessageInfo struct {
+	ID    string `json:"id"`
+	Model string `json:"model"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// merge applies the counts of a later event. message_delta reports cumulative
+// counts and omits the fields that did not change.
+func (u *Usage) merge(next Usage) {
+	if next.InputTokens > 0 {
+		u.InputTokens = next.InputTokens
+	}
+	if next.OutputTokens > 0 {
+		u.OutputTokens = next.OutputTokens
+	}
+	if next.CacheCreationInputTokens > 0 {
+		u.CacheCreationInputTokens = next.CacheCreationInputTokens
+	}
+	if next.CacheReadInputTokens > 0 {
+		u.CacheReadInputTokens = next.CacheReadInputTokens
+	}
+}
+
+func (u Usage) toUsage(model string) llmstreamer.Usage {
+	usage := llmstreamer.Usage{
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+	if price, ok := PriceFor(model); ok {
+		usage.Cost = price.Cost(usage)
+	}
+	return usage
 }
 
 type DeltaData struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json,omitempty"`
+
+	// message_delta
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
+}
+
+func stopReason(reason string) llmstreamer.StopReason {
+	switch reason {
+	case "end_turn":
+		return llmstreamer.StopReasonEndTurn
+	case "max_tokens":
+		return llmstreamer.StopReasonMaxTokens
+	case "stop_sequence":
+		return llmstreamer.StopReasonStopSequence
+	case "tool_use":
+		return llmstreamer.StopReasonToolUse
+	case "refusal":
+		return llmstreamer.StopReasonContentFilter
+	}
+	return llmstreamer.StopReasonOther
 }
@@ -0,0 +1,229 @@
+package azureopenai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/openai"
+)
+
+const DefaultAPIVersion = "2024-10-21"
+
+// TokenFunc returns a Microsoft Entra ID access token for the
+// https://cognitiveservices.azure.com/.default scope. It is called before
+// every request, so it should cache tokens until they expire.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// AzureStreamer streams chat completions from an Azure OpenAI deployment. It
+// uses the same wire format as the openai package and shares its parser.
+type AzureStreamer struct {
+	// Endpoint is the resource endpoint, such as
+	// "https://my-resource.openai.azure.com".
+	Endpoint   string
+	Deployment string
+	// APIVersion is sent as the api-version query parameter. Empty means
+	// DefaultAPIVersion.
+	APIVersion string
+
+	// ApiKey is sent in the api-key header. Token, if set, is used
+	// instead to send an Entra ID bearer token.
+	ApiKey string
+	Token  TokenFunc
+
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// Header is added to every request. It overrides the headers set by the
+	// streamer.
+	Header http.Header
+}
+
+func New(endpoint, deployment, apiKey string) *AzureStreamer {
+	return &AzureStreamer{
+		Endpoint:   endpoint,
+		Deployment: deployment,
+		ApiKey:     apiKey,
+	}
+}
+
+// NewWithToken returns a streamer that authenticates with Entra ID tokens.
+func NewWithToken(endpoint, deployment string, token TokenFunc) *AzureStreamer {
+	return &AzureStreamer{
+		Endpoint:   endpoint,
+		Deployment: deployment,
+		Token:      token,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*AzureStreamer)(nil)
+	_ llmstreamer.Warmer   = (*AzureStreamer)(nil)
+)
+
+// The registered factory reads the resource endpoint from Config.BaseURL and
+// the deployment name from Config.Model.
+func init() {
+	llmstreamer.Register("azureopenai", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		if cfg.BaseURL == "" || cfg.Model == "" {
+			return nil, errors.New("azureopenai: BaseURL (the resource endpoint) and Model (the deployment) are required")
+		}
+		s := New(cfg.BaseURL, cfg.Model, cfg.APIKey)
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.Header = cfg.Header
+		s.APIVersion = cfg.APIVersion
+		return s, nil
+	})
+}
+
+func (s *AzureStreamer) StreamChat(
+	ctx context.Context,
+	messages []llmstreamer.Message,
+	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
+) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
+	if s.ApiKey == "" && s.Token == nil {
+		cb.EmitError(errors.New("invalid apiKey"))
+		return
+	}
+	if s.Endpoint == "" || s.Deployment == "" {
+		cb.EmitError(errors.New("azureopenai: endpoint and deployment are required"))
+		return
+	}
+
+	// The deployment decides the model; the name is only used to pick the
+	// message format.
+	o := llmstreamer.NewOptions(opts...)
+	payload, err := openai.NewRequestBody(openai.Model(s.Deployment), messages, o)
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+	onFilter, _ := o.Extension(contentFilterKey{}).(func(ContentFilterEvent))
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, payload, cb, onFilter)
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *AzureStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *AzureStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint())
+}
+
+func (s *AzureStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *AzureStreamer) stream(ctx context.Context, payload openai.RequestBody, cb *llmstreamer.StreamCallbacks, onFilter func(ContentFilterEvent)) error {
+	client, req, err := s.prepareRequest(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+
+	// A prompt rejected by the content filter is answered with an error that
+	// carries the filter results, so the body of an error is kept.
+	var errBody bytes.Buffer
+	if resp.StatusCode != http.StatusOK {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(resp.Body, &errBody), resp.Body}
+	}
+
+	filters := contentFilters{on: onFilter}
+	err = openai.ProcessStream(resp, filters.callbacks(cb), filters.add)
+	if errBody.Len() > 0 {
+		filters.addError(errBody.Bytes())
+	}
+
+	var apiErr *llmstreamer.APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Provider = "azureopenai"
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get("apim-request-id")
+		}
+	}
+	return err
+}
+
+func (s *AzureStreamer) prepareRequest(ctx context.Context, payload openai.RequestBody) (*http.Client, *http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.Token != nil {
+		token, err := s.Token(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("azureopenai: get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("api-key", s.ApiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	llmstreamer.SetHeaders(req.Header, s.Header)
+
+	client := s.client()
+
+	return client, req, nil
+}
+
+func (s *AzureStreamer) endpoint() string {
+	version := s.APIVersion
+	if version == "" {
+		version = DefaultAPIVersion
+	}
+	return strings.TrimSuffix(s.Endpoint, "/") +
+		"/openai/deployments/" + url.PathEscape(s.Deployment) +
+		"/chat/completions?api-version=" + url.QueryEscape(version)
+}
@@ -0,0 +1,199 @@
+package azureopenai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+func TestStreamChat_ContentFilter(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "content_filter.sse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotPath, gotVersion, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL+"/", "my-gpt4o", "azure-key")
+
+	var events []ContentFilterEvent
+	eventsBeforeContent := -1
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) {
+			if eventsBeforeContent < 0 {
+				eventsBeforeContent = len(events)
+			}
+		},
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}, WithContentFilter(func(ev ContentFilterEvent) { events = append(events, ev) }))
+
+	if gotPath != "/openai/deployments/my-gpt4o/chat/completions" || gotVersion != DefaultAPIVersion || gotKey != "azure-key" {
+		t.Fatalf("path = %q, api-version = %q, api-key = %q", gotPath, gotVersion, gotKey)
+	}
+	if result.Message != "Here is" || result.StopReason != llmstreamer.StopReasonContentFilter {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Model != "gpt-4o-2024-08-06" || result.Usage == nil || result.Usage.OutputTokens != 3 {
+		t.Fatalf("unexpected metadata: %+v", result)
+	}
+
+	if metadata, _ := result.Metadata.(ContentFilterEvents); !reflect.DeepEqual([]ContentFilterEvent(metadata), events) {
+		t.Fatalf("metadata = %+v, want the events %+v", result.Metadata, events)
+	}
+	// The events are delivered as the chunks arrive, so the prompt results
+	// come before the content.
+	if eventsBeforeContent < 1 {
+		t.Fatalf("%d events before the first content", eventsBeforeContent)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 filter events, got %d: %+v", len(events), events)
+	}
+	if events[0].Source != FilterSourcePrompt || len(events[0].Results) != 5 || events[0].Results["jailbreak"].Detected == nil {
+		t.Fatalf("unexpected prompt event: %+v", events[0])
+	}
+	last := events[2]
+	if last.Source != FilterSourceCompletion || !reflect.DeepEqual(last.Results.Filtered(), []string{"violence"}) {
+		t.Fatalf("unexpected completion event: %+v", last)
+	}
+	if last.Results["violence"].Severity != "high" {
+		t.Fatalf("unexpected severity: %+v", last.Results["violence"])
+	}
+}
+
+func TestStreamChat_EntraToken(t *testing.T) {
+	var gotAuth, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotKey = r.Header.Get("api-key")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n"+"data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	s := NewWithToken(srv.URL, "dep", func(ctx context.Context) (string, error) { return "entra-token", nil })
+	s.APIVersion = "2025-01-01-preview"
+
+	var final string
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if final != "ok" || gotAuth != "Bearer entra-token" || gotKey != "" {
+		t.Fatalf("final = %q, Authorization = %q, api-key = %q", final, gotAuth, gotKey)
+	}
+}
+
+func TestStreamChat_TokenError(t *testing.T) {
+	s := NewWithToken("https://example.openai.azure.com", "dep", func(ctx context.Context) (string, error) {
+		return "", errors.New("no credentials")
+	})
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{OnError: func(err error) { gotErr = err }})
+
+	if gotErr == nil || gotErr.Error() != "azureopenai: get token: no credentials" {
+		t.Fatalf("unexpected error: %v", gotErr)
+	}
+}
+
+func TestStreamChat_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apim-request-id", "apim-123")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error":{"code":"429","message":"Requests to the ChatCompletions_Create Operation have exceeded call rate limit."}}`)
+	}))
+	defer srv.Close()
+
+	var gotErr error
+	New(srv.URL, "dep", "key").StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Provider != "azureopenai" || apiErr.RequestID != "apim-123" || !errors.Is(gotErr, llmstreamer.ErrRateLimited) {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s, err := llmstreamer.Open("azureopenai", llmstreamer.Config{APIKey: "k", Model: "dep", BaseURL: "https://example.openai.azure.com", APIVersion: "2025-01-01-preview"})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if az, ok := s.(*AzureStreamer); !ok || az.Deployment != "dep" || az.Endpoint != "https://example.openai.azure.com" || az.APIVersion != "2025-01-01-preview" {
+		t.Fatalf("unexpected streamer: %#v", s)
+	}
+
+	if _, err := llmstreamer.Open("azureopenai", llmstreamer.Config{APIKey: "k"}); err == nil {
+		t.Fatalf("expected an error without endpoint and deployment")
+	}
+}
+
+func TestStreamChat_PromptFiltered(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"message":"The response was filtered due to the prompt triggering Azure OpenAI's content management policy.","type":null,"param":"prompt","code":"content_filter","status":400,"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"hate":{"filtered":false,"severity":"safe"},"jailbreak":{"filtered":false,"detected":false},"violence":{"filtered":true,"severity":"medium"}}}}}`)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL, "dep", "azure-key")
+
+	var events []ContentFilterEvent
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	}, WithContentFilter(func(ev ContentFilterEvent) { events = append(events, ev) }))
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) || apiErr.Code != "content_filter" || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error: %v", gotErr)
+	}
+	if len(events) != 1 || events[0].Source != FilterSourcePrompt || !reflect.DeepEqual(events[0].Results.Filtered(), []string{"violence"}) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestStreamChat_FilterEventsBeforeStreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `data: {"choices":[],"prompt_filter_results":[{"prompt_index":0,"content_filter_results":{"hate":{"filtered":false,"severity":"safe"}}}]}`+"\n\n"+
+			`data: {"choices":[{"index":0,"delta":{"content":"Hel"},"content_filter_results":{"violence":{"filtered":false,"severity":"low"}}}]}`+"\n\n"+
+			`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error","code":null}}`+"\n\n")
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL, "dep", "azure-key")
+
+	var events []ContentFilterEvent
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { t.Fatalf("unexpected finish: %q", f) },
+		OnError:  func(err error) { gotErr = err },
+	}, WithContentFilter(func(ev ContentFilterEvent) { events = append(events, ev) }))
+
+	if gotErr == nil {
+		t.Fatal("expected an error")
+	}
+	if len(events) != 2 || events[0].Source != FilterSourcePrompt || events[1].Results["violence"].Severity != "low" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,151 @@
+package azureopenai
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/openai"
+)
+
+type FilterSource string
+
+const (
+	FilterSourcePrompt     FilterSource = "prompt"
+	FilterSourceCompletion FilterSource = "completion"
+)
+
+// FilterResult is the verdict for one content filter category. Severity is
+// set for the harm categories (hate, sexual, violence, self_harm); Detected
+// is set for the others, such as jailbreak or protected_material_text.
+type FilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"`
+	Detected *bool  `json:"detected,omitempty"`
+}
+
+// ContentFilterResults maps a filter category to its result.
+type ContentFilterResults map[string]FilterResult
+
+// Filtered returns the categories that caused content to be filtered, sorted.
+func (r ContentFilterResults) Filtered() []string {
+	var categories []string
+	for name, result := range r {
+		if result.Filtered {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// ContentFilterEvent carries the content filter results for a prompt or for a
+// choice of the response. A response whose content was filtered also finishes
+// with llmstreamer.StopReasonContentFilter.
+type ContentFilterEvent struct {
+	Source FilterSource
+	// Index is the prompt index for prompt results and the choice index for
+	// completion results.
+	Index   int
+	Results ContentFilterResults
+}
+
+type promptFilterResult struct {
+	PromptIndex          int                  `json:"prompt_index"`
+	ContentFilterResults ContentFilterResults `json:"content_filter_results"`
+}
+
+// ContentFilterEvents are the content filter results Azure attached to the
+// prompt and to the generated content of a response, in the order they
+// arrived. They are also delivered in llmstreamer.FinishResult.Metadata.
+type ContentFilterEvents []ContentFilterEvent
+
+type contentFilterKey struct{}
+
+// WithContentFilter returns an option that calls fn with each content filter
+// event of the request as it arrives, including the results sent with an
+// error when the prompt itself is filtered.
+func WithContentFilter(fn func(ev ContentFilterEvent)) llmstreamer.Option {
+	return llmstreamer.WithExtension(contentFilterKey{}, fn)
+}
+
+// contentFilters collects the content filter events of one response and
+// passes each to on.
+type contentFilters struct {
+	on     func(ev ContentFilterEvent)
+	events ContentFilterEvents
+}
+
+func (f *contentFilters) emit(ev ContentFilterEvent) {
+	f.events = append(f.events, ev)
+	if f.on != nil {
+		f.on(ev)
+	}
+}
+
+// callbacks returns cb with the collected events attached to the finish
+// result.
+func (f *contentFilters) callbacks(cb *llmstreamer.StreamCallbacks) *llmstreamer.StreamCallbacks {
+	if cb == nil || cb.OnFinishResult == nil {
+		return cb
+	}
+	wrapped := *cb
+	wrapped.OnFinishResult = func(result llmstreamer.FinishResult) {
+		if len(f.events) > 0 {
+			result.Metadata = f.events
+		}
+		cb.OnFinishResult(result)
+	}
+	return &wrapped
+}
+
+// add decodes the filter annotations of a chunk. Annotations that fail to
+// decode are skipped.
+func (f *contentFilters) add(ev openai.StreamEvent) {
+	if len(ev.PromptFilterResults) > 0 {
+		var prompts []promptFilterResult
+		if err := json.Unmarshal(ev.PromptFilterResults, &prompts); err == nil {
+			for _, p := range prompts {
+				f.emit(ContentFilterEvent{
+					Source:  FilterSourcePrompt,
+					Index:   p.PromptIndex,
+					Results: p.ContentFilterResults,
+				})
+			}
+		}
+	}
+
+	for _, choice := range ev.Choices {
+		if len(choice.ContentFilterResults) == 0 {
+			continue
+		}
+		var results ContentFilterResults
+		if err := json.Unmarshal(choice.ContentFilterResults, &results); err != nil || len(results) == 0 {
+			continue
+		}
+		f.emit(ContentFilterEvent{
+			Source:  FilterSourceCompletion,
+			Index:   choice.Index,
+			Results: results,
+		})
+	}
+}
+
+// addError decodes the filter results of an error response, sent when the
+// prompt was filtered.
+func (f *contentFilters) addError(body []byte) {
+	var eb struct {
+		Error struct {
+			InnerError struct {
+				ContentFilterResult ContentFilterResults `json:"content_filter_result"`
+			} `json:"innererror"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &eb); err != nil || len(eb.Error.InnerError.ContentFilterResult) == 0 {
+		return
+	}
+	f.emit(ContentFilterEvent{
+		Source:  FilterSourcePrompt,
+		Results: eb.Error.InnerError.ContentFilterResult,
+	})
+}
@@ -0,0 +1,258 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/anthropic"
+)
+
+const anthropicVersion = "bedrock-2023-05-31"
+
+// BedrockStreamer streams Claude responses through Amazon Bedrock's
+// InvokeModelWithResponseStream API. Requests use the Anthropic Messages API
+// format and the streamed events are handled by the anthropic package.
+type BedrockStreamer struct {
+	Region      string
+	Model       Model
+	Credentials CredentialsProvider
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces the regional bedrock-runtime endpoint, for VPC
+	// endpoints and local stand-ins.
+	BaseURL string
+	// Header is added to every request before it is signed.
+	Header http.Header
+
+	// now is the signing clock, replaced in tests.
+	now func() time.Time
+}
+
+func New(region string, model Model, credentials CredentialsProvider) *BedrockStreamer {
+	return &BedrockStreamer{
+		Region:      region,
+		Model:       model,
+		Credentials: credentials,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*BedrockStreamer)(nil)
+	_ llmstreamer.Warmer   = (*BedrockStreamer)(nil)
+)
+
+// The registered factory takes the region from AWS_REGION (or
+// AWS_DEFAULT_REGION) and the credentials from the environment.
+func init() {
+	llmstreamer.Register("bedrock", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if region == "" {
+			return nil, errors.New("bedrock: AWS_REGION is not set")
+		}
+		s := New(region, Model(cfg.Model), EnvCredentials{})
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		return s, nil
+	})
+}
+
+func (s *BedrockStreamer) StreamChat(
+	ctx context.Context,
+	messages []llmstreamer.Message,
+	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
+) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
+	if s.Credentials == nil {
+		cb.EmitError(errors.New("bedrock: no credentials"))
+		return
+	}
+	if s.Region == "" {
+		cb.EmitError(errors.New("bedrock: region is required"))
+		return
+	}
+
+	model := s.Model
+	if model == "" {
+		model = ModelClaude35SonnetV2
+	}
+
+	o := llmstreamer.NewOptions(opts...)
+	payload, err := anthropic.NewRequestBody(anthropic.Model(model), messages, o)
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+	// Bedrock takes the model from the URL and the version from the body.
+	payload.Model = ""
+	payload.Stream = false
+	payload.AnthropicVersion = anthropicVersion
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, model, payload, anthropic.ResponseFormatCallbacks(o, cb))
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *BedrockStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *BedrockStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint())
+}
+
+func (s *BedrockStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *BedrockStreamer) stream(ctx context.Context, model Model, payload anthropic.RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, model, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
+}
+
+func (s *BedrockStreamer) prepareRequest(ctx context.Context, model Model, payload anthropic.RequestBody) (*http.Client, *http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := s.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bedrock: retrieve credentials: %w", err)
+	}
+
+	// Model IDs contain ':', which AWS expects percent-encoded in the path.
+	endpoint := s.endpoint() + "/model/" + uriEncode(string(model)) + "/invoke-with-response-stream"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	llmstreamer.SetHeaders(req.Header, s.Header)
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, data, creds, s.Region, "bedrock", now())
+
+	client := s.client()
+
+	return client, req, nil
+}
+
+func (s *BedrockStreamer) endpoint() string {
+	if s.BaseURL != "" {
+		return strings.TrimSuffix(s.BaseURL, "/")
+	}
+	return "https://bedrock-runtime." + s.Region + ".amazonaws.com"
+}
+
+// processStream decodes the event stream and hands the Anthropic events it
+// carries to anthropic.ProcessEvents.
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
+		if err != nil {
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
+		}
+		return apiErr
+	}
+
+	dec := newEventStreamDecoder(resp.Body)
+	next := func() ([]byte, error) {
+		for {
+			msg, err := dec.next()
+			if err != nil {
+				if err == io.EOF {
+					return nil, io.EOF
+				}
+				return nil, fmt.Errorf("read failed: %w", err)
+			}
+
+			switch msg.headers[":message-type"] {
+			case "exception":
+				return nil, newStreamError(msg.headers[":exception-type"], msg.payload)
+			case "error":
+				return nil, newStreamErrorMessage(msg.headers[":error-code"], msg.headers[":error-message"])
+			case "event":
+				if msg.headers[":event-type"] != "chunk" {
+					continue
+				}
+				// The Anthropic event is base64-encoded in the bytes field,
+				// which encoding/json decodes into a []byte.
+				var chunk struct {
+					Bytes []byte `json:"bytes"`
+				}
+				if err := json.Unmarshal(msg.payload, &chunk); err != nil {
+					return nil, fmt.Errorf("bedrock: invalid chunk: %w", err)
+				}
+				return chunk.Bytes, nil
+			}
+		}
+	}
+
+	err := anthropic.ProcessEvents(next, cb)
+
+	var apiErr *llmstreamer.APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Provider = "bedrock"
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get("x-amzn-RequestId")
+		}
+	}
+	return err
+}
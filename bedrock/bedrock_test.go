@@ -0,0 +1,340 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// encodeMessage builds an event stream message with string headers.
+func encodeMessage(headers map[string]string, payload []byte) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(headerString)
+		binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+
+	total := uint32(preludeLen + h.Len() + len(payload) + messageCRCLen)
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, total)
+	binary.Write(&msg, binary.BigEndian, uint32(h.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(h.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+func chunk(event string) []byte {
+	payload, _ := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(event))})
+	return encodeMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, payload)
+}
+
+func exception(typ, message string) []byte {
+	payload, _ := json.Marshal(map[string]string{"message": message})
+	return encodeMessage(map[string]string{
+		":message-type":   "exception",
+		":exception-type": typ,
+		":content-type":   "application/json",
+	}, payload)
+}
+
+func errorMessage(code, message string) []byte {
+	return encodeMessage(map[string]string{
+		":message-type":  "error",
+		":error-code":    code,
+		":error-message": message,
+	}, nil)
+}
+
+func stream(messages ...[]byte) []byte {
+	return bytes.Join(messages, nil)
+}
+
+func TestSignV4_TestSuite(t *testing.T) {
+	// get-vanilla and post-vanilla from the AWS SigV4 test suite.
+	tests := []struct {
+		method    string
+		signature string
+	}{
+		{http.MethodGet, "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{http.MethodPost, "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	now, _ := time.Parse(amzDateFormat, "20150830T123600Z")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "https://example.amazonaws.com/", nil)
+		signV4(req, nil, creds, "us-east-1", "service", now)
+
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.method, got, want)
+		}
+	}
+}
+
+func TestSignV4_SessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/a%3Ab/invoke", nil)
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, []byte("{}"), Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, "us-east-1", "bedrock", time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Fatalf("unexpected security token: %q", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+		t.Fatalf("unexpected signed headers: %s", auth)
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	got := canonicalURI("/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke-with-response-stream")
+	want := "/model/anthropic.claude-3-haiku-20240307-v1%253A0/invoke-with-response-stream"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestEventStreamDecoder(t *testing.T) {
+	// A header of every non-string type is skipped without losing the
+	// string headers around it.
+	var h bytes.Buffer
+	h.Write([]byte{2, 'b', 't', headerBoolTrue})
+	h.Write([]byte{1, 'i', headerInt, 0, 0, 0, 7})
+	h.Write([]byte{1, 'u', headerUUID})
+	h.Write(make([]byte, 16))
+	h.Write([]byte{1, 'k', headerBytes, 0, 2, 'x', 'y'})
+	h.Write([]byte{1, 's', headerString, 0, 2, 'o', 'k'})
+
+	payload := []byte("hello")
+	total := uint32(preludeLen + h.Len() + len(payload) + messageCRCLen)
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, total)
+	binary.Write(&msg, binary.BigEndian, uint32(h.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(h.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+
+	dec := newEventStreamDecoder(bytes.NewReader(msg.Bytes()))
+	m, err := dec.next()
+	if err != nil {
+		t.Fatalf("next returned error: %v", err)
+	}
+	if string(m.payload) != "hello" || m.headers["s"] != "ok" || len(m.headers) != 1 {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+	if _, err := dec.next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestEventStreamDecoder_Errors(t *testing.T) {
+	good := chunk(`{"type":"message_stop"}`)
+
+	badPrelude := append([]byte(nil), good...)
+	badPrelude[9] ^= 0xff
+
+	badMessage := append([]byte(nil), good...)
+	badMessage[len(badMessage)-6] ^= 0xff
+
+	// A message with valid checksums whose headers length makes the uint32
+	// sum preludeLen+messageCRCLen+headersLen wrap around.
+	var hugeHeaders bytes.Buffer
+	binary.Write(&hugeHeaders, binary.BigEndian, uint32(64))
+	binary.Write(&hugeHeaders, binary.BigEndian, uint32(0xfffffff0))
+	binary.Write(&hugeHeaders, binary.BigEndian, crc32.ChecksumIEEE(hugeHeaders.Bytes()))
+	hugeHeaders.Write(make([]byte, 64-preludeLen-messageCRCLen))
+	binary.Write(&hugeHeaders, binary.BigEndian, crc32.ChecksumIEEE(hugeHeaders.Bytes()))
+
+	tests := map[string][]byte{
+		"prelude crc":     badPrelude,
+		"message crc":     badMessage,
+		"truncated":       good[:len(good)-3],
+		"headers overrun": hugeHeaders.Bytes(),
+	}
+	for name, data := range tests {
+		if _, err := newEventStreamDecoder(bytes.NewReader(data)).next(); err == nil || err == io.EOF {
+			t.Errorf("%s: expected an error, got %v", name, err)
+		}
+	}
+}
+
+func newTestStreamer(t *testing.T, handler http.HandlerFunc) *BedrockStreamer {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	s := New("us-west-2", ModelClaude3Haiku, StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	s.BaseURL = srv.URL
+	return s
+}
+
+func TestStreamChat(t *testing.T) {
+	var gotURI, gotAuth, gotAccept string
+	var body map[string]interface{}
+
+	s := newTestStreamer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+		gotAuth = r.Header.Get("Authorization")
+		gotAccept = r.Header.Get("Accept")
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(stream(
+			chunk(`{"type":"message_start","message":{"id":"msg_bdrk_01","model":"claude-3-haiku-20240307","usage":{"input_tokens":12,"output_tokens":1}}}`),
+			chunk(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`),
+			chunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`),
+			chunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" from Bedrock"}}`),
+			chunk(`{"type":"content_block_stop","index":0}`),
+			chunk(`{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":5}}`),
+			chunk(`{"type":"message_stop","amazon-bedrock-invocationMetrics":{"inputTokenCount":12,"outputTokenCount":5,"invocationLatency":410,"firstByteLatency":280}}`),
+		))
+	})
+
+	var content string
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{
+		{Role: llmstreamer.RoleSystem, Content: "Be brief."},
+		{Role: llmstreamer.RoleUser, Content: "hi"},
+	}, &llmstreamer.StreamCallbacks{
+		OnContent:      func(c string) { content += c },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if gotURI != "/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke-with-response-stream" {
+		t.Fatalf("unexpected request URI: %s", gotURI)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(gotAuth, "/us-west-2/bedrock/aws4_request") {
+		t.Fatalf("unexpected Authorization: %s", gotAuth)
+	}
+	if gotAccept != "application/vnd.amazon.eventstream" {
+		t.Fatalf("unexpected Accept: %s", gotAccept)
+	}
+
+	if body["anthropic_version"] != anthropicVersion || body["system"] != "Be brief." {
+		t.Fatalf("unexpected body: %v", body)
+	}
+	if _, ok := body["model"]; ok {
+		t.Fatalf("model must not be sent in the body: %v", body)
+	}
+	if _, ok := body["stream"]; ok {
+		t.Fatalf("stream must not be sent in the body: %v", body)
+	}
+
+	if content != "Hello from Bedrock" || result.Message != content {
+		t.Fatalf("content = %q, message = %q", content, result.Message)
+	}
+	if result.StopReason != llmstreamer.StopReasonEndTurn || result.ResponseID != "msg_bdrk_01" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 12 || result.Usage.OutputTokens != 5 || result.Usage.Cost == 0 {
+		t.Fatalf("unexpected usage: %+v", result.Usage)
+	}
+}
+
+func TestStreamChat_StreamException(t *testing.T) {
+	s := newTestStreamer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "req-bdrk")
+		w.Write(stream(
+			chunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`),
+			exception("throttlingException", "Too many tokens, please wait before trying again."),
+		))
+	})
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { t.Fatalf("unexpected finish: %q", f) },
+		OnError:  func(err error) { gotErr = err },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Provider != "bedrock" || apiErr.Type != "ThrottlingException" || apiErr.RequestID != "req-bdrk" {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+	if !errors.Is(gotErr, llmstreamer.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited")
+	}
+}
+
+func TestStreamChat_StreamErrorMessage(t *testing.T) {
+	s := newTestStreamer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-RequestId", "req-bdrk")
+		w.Write(stream(
+			chunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`),
+			errorMessage("InternalServerException", "The server encountered an internal error."),
+		))
+	})
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { t.Fatalf("unexpected finish: %q", f) },
+		OnError:  func(err error) { gotErr = err },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Type != "InternalServerException" || apiErr.Message != "The server encountered an internal error." || apiErr.RequestID != "req-bdrk" {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestStreamChat_APIError(t *testing.T) {
+	s := newTestStreamer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amzn-ErrorType", "AccessDeniedException:http://internal.amazon.com/coral/com.amazon.coral.service/")
+		w.Header().Set("x-amzn-RequestId", "req-denied")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message":"You don't have access to the model with the specified model ID."}`)
+	})
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{OnError: func(err error) { gotErr = err }})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Type != "AccessDeniedException" || apiErr.RequestID != "req-denied" || !errors.Is(gotErr, llmstreamer.ErrAuth) {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestStreamChat_CredentialsError(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	var gotErr error
+	New("us-east-1", "", EnvCredentials{}).StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	})
+
+	if gotErr == nil || !strings.Contains(gotErr.Error(), "retrieve credentials") {
+		t.Fatalf("unexpected error: %v", gotErr)
+	}
+}
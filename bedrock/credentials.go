@@ -0,0 +1,45 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// Credentials are AWS access keys. SessionToken is set for temporary
+// credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsProvider supplies the credentials used to sign each request.
+// Implementations that fetch temporary credentials should cache them until
+// they expire.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials provides fixed credentials.
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN on every call.
+type EnvCredentials struct{}
+
+func (EnvCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, errors.New("bedrock: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return c, nil
+}
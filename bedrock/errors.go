@@ -0,0 +1,68 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+type ErrorBody struct {
+	Message string `json:"message"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "bedrock",
+		StatusCode: resp.StatusCode,
+		Type:       errorType(resp.Header.Get("x-amzn-ErrorType")),
+		RequestID:  resp.Header.Get("x-amzn-RequestId"),
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Message == "" {
+		e.Body = string(body)
+		return e
+	}
+	e.Message = eb.Message
+	return e
+}
+
+// newStreamError converts an exception message received in the event stream.
+func newStreamError(exceptionType string, payload []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider: "bedrock",
+		Type:     errorType(exceptionType),
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(payload, &eb); err != nil || eb.Message == "" {
+		e.Body = string(payload)
+		return e
+	}
+	e.Message = eb.Message
+	return e
+}
+
+// newStreamErrorMessage converts an error message received in the event
+// stream, which carries its code and message in headers.
+func newStreamErrorMessage(code, message string) *llmstreamer.APIError {
+	return &llmstreamer.APIError{
+		Provider: "bedrock",
+		Type:     errorType(code),
+		Message:  message,
+	}
+}
+
+// errorType normalizes the exception names AWS uses in headers
+// ("ThrottlingException:http://internal.amazon.com/coral/...") and in the
+// event stream ("throttlingException") to "ThrottlingException".
+func errorType(s string) string {
+	s, _, _ = strings.Cut(s, ":")
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
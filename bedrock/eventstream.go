@@ -0,0 +1,134 @@
+package bedrock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The application/vnd.amazon.eventstream framing: each message is a 12-byte
+// prelude (total length, headers length, prelude CRC), the headers, the
+// payload and a CRC of everything before it. All integers are big-endian and
+// the checksums are CRC-32 (IEEE).
+const (
+	preludeLen    = 12
+	messageCRCLen = 4
+	// maxMessageLen is the largest message AWS sends.
+	maxMessageLen = 16 << 20
+)
+
+// Header value types. Only strings are kept; the others are skipped.
+const (
+	headerBoolTrue byte = iota
+	headerBoolFalse
+	headerByte
+	headerShort
+	headerInt
+	headerLong
+	headerBytes
+	headerString
+	headerTimestamp
+	headerUUID
+)
+
+type eventMessage struct {
+	headers map[string]string
+	payload []byte
+}
+
+type eventStreamDecoder struct {
+	r io.Reader
+}
+
+func newEventStreamDecoder(r io.Reader) *eventStreamDecoder {
+	return &eventStreamDecoder{r: r}
+}
+
+// next reads one message. It returns io.EOF at a clean end of the stream and
+// io.ErrUnexpectedEOF if the stream ends inside a message.
+func (d *eventStreamDecoder) next() (eventMessage, error) {
+	var prelude [preludeLen]byte
+	if _, err := io.ReadFull(d.r, prelude[:]); err != nil {
+		return eventMessage{}, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc := crc32.ChecksumIEEE(prelude[:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return eventMessage{}, errors.New("eventstream: prelude checksum mismatch")
+	}
+	// headersLen is compared by subtraction, which cannot overflow once
+	// totalLen covers the prelude and checksum.
+	if totalLen > maxMessageLen || totalLen < preludeLen+messageCRCLen || headersLen > totalLen-preludeLen-messageCRCLen {
+		return eventMessage{}, fmt.Errorf("eventstream: invalid message length %d", totalLen)
+	}
+
+	msg := make([]byte, totalLen)
+	copy(msg, prelude[:])
+	if _, err := io.ReadFull(d.r, msg[preludeLen:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return eventMessage{}, err
+	}
+
+	end := totalLen - messageCRCLen
+	if crc := crc32.ChecksumIEEE(msg[:end]); crc != binary.BigEndian.Uint32(msg[end:]) {
+		return eventMessage{}, errors.New("eventstream: message checksum mismatch")
+	}
+
+	headers, err := decodeHeaders(msg[preludeLen : preludeLen+headersLen])
+	if err != nil {
+		return eventMessage{}, err
+	}
+	return eventMessage{headers: headers, payload: msg[preludeLen+headersLen : end]}, nil
+}
+
+func decodeHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	errShort := errors.New("eventstream: truncated header")
+
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errShort
+		}
+		name := string(b[1 : 1+nameLen])
+		typ := b[1+nameLen]
+		b = b[2+nameLen:]
+
+		var size int
+		switch typ {
+		case headerBoolTrue, headerBoolFalse:
+			size = 0
+		case headerByte:
+			size = 1
+		case headerShort:
+			size = 2
+		case headerInt:
+			size = 4
+		case headerLong, headerTimestamp:
+			size = 8
+		case headerUUID:
+			size = 16
+		case headerBytes, headerString:
+			if len(b) < 2 {
+				return nil, errShort
+			}
+			size = 2 + int(binary.BigEndian.Uint16(b))
+		default:
+			return nil, fmt.Errorf("eventstream: unknown header type %d", typ)
+		}
+
+		if len(b) < size {
+			return nil, errShort
+		}
+		if typ == headerString {
+			headers[name] = string(b[2:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
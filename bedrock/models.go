@@ -0,0 +1,16 @@
+package bedrock
+
+// Model is a Bedrock model ID. Cross-region inference profiles, such as
+// "us.anthropic.claude-3-5-sonnet-20241022-v2:0", and provisioned throughput
+// ARNs can be used as Model values as well.
+type Model string
+
+const (
+	ModelClaude35SonnetV2 Model = "anthropic.claude-3-5-sonnet-20241022-v2:0"
+	ModelClaude35Sonnet   Model = "anthropic.claude-3-5-sonnet-20240620-v1:0"
+	ModelClaude35Haiku    Model = "anthropic.claude-3-5-haiku-20241022-v1:0"
+
+	ModelClaude3Opus   Model = "anthropic.claude-3-opus-20240229-v1:0"
+	ModelClaude3Sonnet Model = "anthropic.claude-3-sonnet-20240229-v1:0"
+	ModelClaude3Haiku  Model = "anthropic.claude-3-haiku-20240307-v1:0"
+)
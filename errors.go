@@ -0,0 +1,137 @@
+package llmstreamer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRateLimited   = errors.New("llmstreamer: rate limited")
+	ErrOverloaded    = errors.New("llmstreamer: provider overloaded")
+	ErrAuth          = errors.New("llmstreamer: authentication failed")
+	ErrContextLength = errors.New("llmstreamer: context length exceeded")
+)
+
+// APIError is returned when a provider rejects a request. It matches the
+// sentinel errors above with errors.Is:
+//
+//	var apiErr *llmstreamer.APIError
+//	if errors.As(err, &apiErr) && errors.Is(err, llmstreamer.ErrRateLimited) {
+//		time.Sleep(apiErr.RetryAfter)
+//	}
+type APIError struct {
+	Provider string
+	// StatusCode is 0 for errors reported inside an otherwise successful
+	// stream.
+	StatusCode int
+	// Type and Code are the provider's error classification, such as
+	// "rate_limit_error" or "context_length_exceeded".
+	Type    string
+	Code    string
+	Message string
+
+	RequestID  string
+	RetryAfter time.Duration
+
+	// Body is the raw response body when it could not be parsed.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	if e.Provider != "" {
+		b.WriteString(e.Provider)
+		b.WriteString(": ")
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, "non-200: %d, ", e.StatusCode)
+	}
+
+	kind := e.Type
+	if e.Code != "" && e.Code != e.Type {
+		if kind != "" {
+			kind += "/"
+		}
+		kind += e.Code
+	}
+
+	switch {
+	case kind != "" && e.Message != "":
+		fmt.Fprintf(&b, "%s: %s", kind, e.Message)
+	case e.Message != "":
+		b.WriteString(e.Message)
+	case kind != "":
+		b.WriteString(kind)
+	default:
+		fmt.Fprintf(&b, "body: %s", e.Body)
+	}
+
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		if e.Code == "insufficient_quota" {
+			return false
+		}
+		return e.StatusCode == http.StatusTooManyRequests ||
+			e.Type == "rate_limit_error" || e.Code == "rate_limit_exceeded" ||
+			e.Type == "RESOURCE_EXHAUSTED" || e.Type == "ThrottlingException"
+	case ErrOverloaded:
+		return e.StatusCode == 529 || e.StatusCode == http.StatusServiceUnavailable ||
+			e.Type == "overloaded_error" || e.Type == "UNAVAILABLE" ||
+			e.Type == "ServiceUnavailableException" || e.Type == "ModelNotReadyException"
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+			e.Type == "authentication_error" || e.Type == "permission_error" ||
+			e.Code == "invalid_api_key" || e.Code == "API_KEY_INVALID" ||
+			e.Type == "UNAUTHENTICATED" || e.Type == "PERMISSION_DENIED" ||
+			e.Type == "AccessDeniedException" || e.Type == "UnrecognizedClientException" ||
+			e.Type == "invalid_grant"
+	case ErrContextLength:
+		if e.Code == "context_length_exceeded" {
+			return true
+		}
+		msg := strings.ToLower(e.Message)
+		return strings.Contains(msg, "prompt is too long") ||
+			strings.Contains(msg, "maximum context length") ||
+			strings.Contains(msg, "context window") ||
+			strings.Contains(msg, "maximum number of tokens allowed") ||
+			strings.Contains(msg, "input is too long")
+	}
+	return false
+}
+
+// ParseRetryAfter reads how long the provider asked the client to wait, from
+// the retry-after-ms or Retry-After header (in seconds or as an HTTP date). It
+// returns 0 if neither header is present.
+func ParseRetryAfter(h http.Header) time.Duration {
+	if v := h.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
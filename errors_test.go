@@ -0,0 +1,100 @@
+package llmstreamer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *APIError
+		want []error
+		not  []error
+	}{
+		{"429", &APIError{StatusCode: 429}, []error{ErrRateLimited}, []error{ErrOverloaded, ErrAuth}},
+		{"anthropic rate limit", &APIError{Type: "rate_limit_error"}, []error{ErrRateLimited}, nil},
+		{"insufficient quota", &APIError{StatusCode: 429, Code: "insufficient_quota"}, nil, []error{ErrRateLimited}},
+		{"529", &APIError{StatusCode: 529, Type: "overloaded_error"}, []error{ErrOverloaded}, []error{ErrRateLimited}},
+		{"503", &APIError{StatusCode: 503}, []error{ErrOverloaded}, nil},
+		{"401", &APIError{StatusCode: 401}, []error{ErrAuth}, nil},
+		{"permission", &APIError{StatusCode: 403, Type: "permission_error"}, []error{ErrAuth}, nil},
+		{"openai context", &APIError{StatusCode: 400, Code: "context_length_exceeded"}, []error{ErrContextLength}, []error{ErrAuth}},
+		{"anthropic context", &APIError{StatusCode: 400, Type: "invalid_request_error", Message: "prompt is too long: 210000 tokens > 200000 maximum"}, []error{ErrContextLength}, nil},
+		{"gemini quota", &APIError{Type: "RESOURCE_EXHAUSTED"}, []error{ErrRateLimited}, nil},
+		{"gemini unavailable", &APIError{Type: "UNAVAILABLE"}, []error{ErrOverloaded}, nil},
+		{"gemini api key", &APIError{StatusCode: 400, Type: "INVALID_ARGUMENT", Code: "API_KEY_INVALID"}, []error{ErrAuth}, nil},
+		{"gemini context", &APIError{StatusCode: 400, Message: "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."}, []error{ErrContextLength}, nil},
+		{"bedrock throttling", &APIError{Type: "ThrottlingException"}, []error{ErrRateLimited}, nil},
+		{"bedrock unavailable", &APIError{Type: "ServiceUnavailableException"}, []error{ErrOverloaded}, nil},
+		{"bedrock context", &APIError{StatusCode: 400, Type: "ValidationException", Message: "Input is too long for requested model."}, []error{ErrContextLength}, []error{ErrAuth}},
+		{"vertex invalid grant", &APIError{StatusCode: 400, Type: "invalid_grant", Message: "Invalid JWT Signature."}, []error{ErrAuth}, []error{ErrRateLimited}},
+		{"bad request", &APIError{StatusCode: 400, Message: "invalid model"}, nil, []error{ErrRateLimited, ErrOverloaded, ErrAuth, ErrContextLength}},
+	}
+
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", tt.err)
+		for _, target := range tt.want {
+			if !errors.Is(wrapped, target) {
+				t.Fatalf("%s: expected errors.Is(%v)", tt.name, target)
+			}
+		}
+		for _, target := range tt.not {
+			if errors.Is(wrapped, target) {
+				t.Fatalf("%s: unexpected errors.Is(%v)", tt.name, target)
+			}
+		}
+	}
+}
+
+func TestAPIError_Error(t *testing.T) {
+	err := &APIError{
+		Provider:   "openai",
+		StatusCode: 400,
+		Type:       "invalid_request_error",
+		Code:       "context_length_exceeded",
+		Message:    "too long",
+		RequestID:  "req_1",
+	}
+	want := "openai: non-200: 400, invalid_request_error/context_length_exceeded: too long (request id req_1)"
+	if err.Error() != want {
+		t.Fatalf("unexpected message:\n got %q\nwant %q", err.Error(), want)
+	}
+
+	raw := &APIError{StatusCode: 502, Body: "<html>bad gateway</html>"}
+	if !strings.Contains(raw.Error(), "body: <html>bad gateway</html>") {
+		t.Fatalf("expected raw body in message, got %q", raw.Error())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	h := http.Header{}
+	if d := ParseRetryAfter(h); d != 0 {
+		t.Fatalf("expected 0 without headers, got %v", d)
+	}
+
+	h.Set("Retry-After", "3")
+	if d := ParseRetryAfter(h); d != 3*time.Second {
+		t.Fatalf("expected 3s, got %v", d)
+	}
+
+	h.Set("retry-after-ms", "1500")
+	if d := ParseRetryAfter(h); d != 1500*time.Millisecond {
+		t.Fatalf("expected retry-after-ms to take precedence, got %v", d)
+	}
+
+	h = http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d := ParseRetryAfter(h); d <= 50*time.Second || d > time.Minute {
+		t.Fatalf("expected about a minute from HTTP date, got %v", d)
+	}
+
+	h.Set("Retry-After", "soon")
+	if d := ParseRetryAfter(h); d != 0 {
+		t.Fatalf("expected 0 for invalid value, got %v", d)
+	}
+}
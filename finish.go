@@ -0,0 +1,42 @@
+package llmstreamer
+
+type StopReason string
+
+const (
+	StopReasonEndTurn       StopReason = "end_turn"
+	StopReasonMaxTokens     StopReason = "max_tokens"
+	StopReasonStopSequence  StopReason = "stop_sequence"
+	StopReasonToolUse       StopReason = "tool_use"
+	StopReasonContentFilter StopReason = "content_filter"
+	StopReasonOther         StopReason = "other"
+)
+
+// FinishResult describes a completed response. StopReason is normalized
+// across providers; RawStopReason keeps the provider's own value. Fields the
+// provider did not report are left empty.
+type FinishResult struct {
+	Message string
+	// Reasoning is the reasoning text delivered through OnReasoning.
+	Reasoning string
+
+	StopReason    StopReason
+	RawStopReason string
+	// StopSequence is the stop sequence that ended the response, when the
+	// provider reports it.
+	StopSequence string
+
+	ResponseID        string
+	Model             string
+	SystemFingerprint string
+
+	ToolCalls []ToolCall
+	Usage     *Usage
+	// Stats describes the latency of the response. It is set by the built-in
+	// providers; see TrackStats.
+	Stats *StreamStats
+	// Metadata holds provider-specific details of the response, such as the
+	// ollama.Metrics of an Ollama response or the
+	// azureopenai.ContentFilterEvents of an Azure OpenAI one. Type-assert it
+	// to the provider's type.
+	Metadata any
+}
@@ -0,0 +1,39 @@
+package llmstreamer
+
+import "testing"
+
+func TestEmitFinishResult(t *testing.T) {
+	var order []string
+	var got FinishResult
+
+	cb := &StreamCallbacks{
+		OnFinishResult: func(r FinishResult) {
+			got = r
+			order = append(order, "result")
+		},
+		OnFinish: func(m string) {
+			if m != "done" {
+				t.Fatalf("unexpected final message %q", m)
+			}
+			order = append(order, "finish")
+		},
+	}
+
+	cb.EmitFinishResult(FinishResult{Message: "done", StopReason: StopReasonMaxTokens})
+
+	if got.StopReason != StopReasonMaxTokens {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if len(order) != 2 || order[0] != "result" || order[1] != "finish" {
+		t.Fatalf("expected result before finish, got %v", order)
+	}
+
+	var finished bool
+	(&StreamCallbacks{OnFinish: func(string) { finished = true }}).EmitFinishResult(FinishResult{})
+	if !finished {
+		t.Fatalf("OnFinish must be called without OnFinishResult")
+	}
+
+	var nilCb *StreamCallbacks
+	nilCb.EmitFinishResult(FinishResult{})
+}
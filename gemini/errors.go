@@ -0,0 +1,68 @@
+package gemini
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+type ErrorBody struct {
+	Error *ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Status  string        `json:"status"`
+	Details []ErrorReason `json:"details,omitempty"`
+}
+
+// ErrorReason is an entry of the google.rpc error details. Only the fields
+// used by the client are decoded.
+type ErrorReason struct {
+	Type       string `json:"@type"`
+	Reason     string `json:"reason,omitempty"`
+	RetryDelay string `json:"retryDelay,omitempty"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "gemini",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Error == nil {
+		e.Body = string(body)
+		return e
+	}
+	eb.Error.apply(e)
+	return e
+}
+
+// newStreamError converts an error object received in a data line after a 200
+// response.
+func newStreamError(d *ErrorDetail) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{Provider: "gemini"}
+	d.apply(e)
+	return e
+}
+
+func (d *ErrorDetail) apply(e *llmstreamer.APIError) {
+	e.Type = d.Status
+	e.Message = d.Message
+	for _, r := range d.Details {
+		if r.Reason != "" && e.Code == "" {
+			e.Code = r.Reason
+		}
+		// RetryInfo carries the delay as a duration string such as "53s".
+		if r.RetryDelay != "" && e.RetryAfter == 0 {
+			if delay, err := time.ParseDuration(r.RetryDelay); err == nil {
+				e.RetryAfter = delay
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/sse"
+)
+
+type GeminiStreamer struct {
+	ApiKey string
+	Model  Model
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces DefaultBaseURL, for proxies and local stand-ins.
+	BaseURL string
+	// Header is added to every request. It overrides the headers set by the
+	// streamer.
+	Header http.Header
+}
+
+func New(apiKey string, model Model) *GeminiStreamer {
+	return &GeminiStreamer{
+		ApiKey: apiKey,
+		Model:  model,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*GeminiStreamer)(nil)
+	_ llmstreamer.Warmer   = (*GeminiStreamer)(nil)
+)
+
+func init() {
+	llmstreamer.Register("gemini", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		s := New(cfg.APIKey, Model(cfg.Model))
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		return s, nil
+	})
+}
+
+const DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+func (s *GeminiStreamer) StreamChat(
+	ctx context.Context,
+	messages []llmstreamer.Message,
+	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
+) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
+	if s.ApiKey == "" {
+		cb.EmitError(errors.New("invalid apiKey"))
+		return
+	}
+
+	payload, err := newRequestBody(messages, llmstreamer.NewOptions(opts...))
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, payload, cb)
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *GeminiStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *GeminiStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint())
+}
+
+func (s *GeminiStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *GeminiStreamer) stream(ctx context.Context, payload RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
+}
+
+func (s *GeminiStreamer) prepareRequest(ctx context.Context, payload RequestBody) (*http.Client, *http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("x-goog-api-key", s.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+	llmstreamer.SetHeaders(req.Header, s.Header)
+
+	client := s.client()
+
+	return client, req, nil
+}
+
+func (s *GeminiStreamer) endpoint() string {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	model := s.Model
+	if model == "" {
+		model = ModelGemini25Flash
+	}
+	return strings.TrimSuffix(base, "/") + "/models/" + url.PathEscape(string(model)) + ":streamGenerateContent?alt=sse"
+}
+
+// processStream delivers the response to cb. Errors that end the stream are
+// returned rather than emitted so the caller can decide whether to retry.
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
+		if err != nil {
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
+		}
+		return apiErr
+	}
+
+	dec := sse.NewDecoder(resp.Body)
+	dec.DispatchAtEOF = true
+	defer dec.Release()
+
+	var (
+		finalMessage strings.Builder
+		reasoning    strings.Builder
+		result       llmstreamer.FinishResult
+		usage        *UsageMetadata
+	)
+
+	finish := func() {
+		if usage != nil {
+			u := usage.toUsage(result.Model)
+			result.Usage = &u
+			cb.EmitUsage(u)
+		}
+		// Gemini reports STOP after function calls.
+		if result.StopReason == llmstreamer.StopReasonEndTurn && len(result.ToolCalls) > 0 {
+			result.StopReason = llmstreamer.StopReasonToolUse
+		}
+		result.Message = finalMessage.String()
+		result.Reasoning = reasoning.String()
+		cb.EmitFinishResult(result)
+	}
+
+	for {
+		event, err := dec.Next()
+		if err != nil {
+			if err == io.EOF {
+				finish()
+				return nil
+			}
+			return fmt.Errorf("read failed: %w", err)
+		}
+
+		var ev StreamEvent
+		if err := json.Unmarshal(event.Data, &ev); err != nil {
+			cb.EmitError(fmt.Errorf("failed to parse JSON: %w", err))
+			continue
+		}
+		if ev.Error != nil {
+			return newStreamError(ev.Error)
+		}
+
+		if ev.ResponseID != "" {
+			result.ResponseID = ev.ResponseID
+		}
+		if ev.ModelVersion != "" {
+			result.Model = ev.ModelVersion
+		}
+		if ev.UsageMetadata != nil {
+			usage = ev.UsageMetadata
+		}
+
+		// A blocked prompt produces no candidates, only the block reason.
+		if fb := ev.PromptFeedback; fb != nil && fb.BlockReason != "" {
+			result.RawStopReason = fb.BlockReason
+			result.StopReason = llmstreamer.StopReasonContentFilter
+		}
+
+		if len(ev.Candidates) == 0 {
+			continue
+		}
+		candidate := ev.Candidates[0]
+
+		if candidate.Content != nil {
+			for _, part := range candidate.Content.Parts {
+				switch {
+				case part.FunctionCall != nil:
+					call := llmstreamer.ToolCall{
+						ID:        part.FunctionCall.ID,
+						Name:      part.FunctionCall.Name,
+						Arguments: string(part.FunctionCall.Args),
+					}
+					if call.ID == "" {
+						call.ID = generatedIDPrefix + strconv.Itoa(len(result.ToolCalls))
+					}
+					if call.Arguments == "" {
+						call.Arguments = "{}"
+					}
+					// Function calls arrive whole, so the arguments are
+					// delivered as a single delta.
+					cb.EmitToolCallStart(llmstreamer.ToolCall{ID: call.ID, Name: call.Name})
+					cb.EmitToolCallDelta(call.ID, call.Arguments)
+					cb.EmitToolCall(call)
+					result.ToolCalls = append(result.ToolCalls, call)
+				case part.Thought:
+					if part.Text != "" {
+						reasoning.WriteString(part.Text)
+						cb.EmitReasoning(part.Text)
+					}
+				case part.Text != "":
+					finalMessage.WriteString(part.Text)
+					cb.EmitContent(part.Text)
+				}
+			}
+		}
+
+		if candidate.FinishReason != "" {
+			result.RawStopReason = candidate.FinishReason
+			result.StopReason = stopReason(candidate.FinishReason)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// serveFixture starts a server that replays a recorded stream and records the
+// request it received.
+func serveFixture(t *testing.T, name string, req **http.Request, body *RequestBody) *GeminiStreamer {
+	t.Helper()
+
+	fixture, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req != nil {
+			*req = r
+		}
+		if body != nil {
+			json.NewDecoder(r.Body).Decode(body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(fixture)
+	}))
+	t.Cleanup(srv.Close)
+
+	s := New("test-key", ModelGemini20Flash)
+	s.BaseURL = srv.URL + "/v1beta"
+	return s
+}
+
+func collect(t *testing.T, s *GeminiStreamer, messages []llmstreamer.Message, opts ...llmstreamer.Option) (string, llmstreamer.FinishResult) {
+	t.Helper()
+
+	var content string
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), messages, &llmstreamer.StreamCallbacks{
+		OnContent:      func(c string) { content += c },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}, opts...)
+	return content, result
+}
+
+func TestStreamChat_Text(t *testing.T) {
+	var req *http.Request
+	var body RequestBody
+	s := serveFixture(t, "text.sse", &req, &body)
+
+	content, result := collect(t, s, []llmstreamer.Message{
+		{Role: llmstreamer.RoleSystem, Content: "You are terse."},
+		{Role: llmstreamer.RoleDeveloper, Content: "Answer in English."},
+		{Role: llmstreamer.RoleUser, Content: "hi"},
+		{Role: llmstreamer.RoleAssistant, Content: "Hello."},
+		{Role: llmstreamer.RoleUser, Content: "hi again"},
+	}, llmstreamer.WithMaxTokens(100), llmstreamer.WithTemperature(0.5), llmstreamer.WithStop("END"))
+
+	if req.URL.Path != "/v1beta/models/gemini-2.0-flash:streamGenerateContent" || req.URL.Query().Get("alt") != "sse" {
+		t.Fatalf("unexpected URL: %v", req.URL)
+	}
+	if got := req.Header.Get("x-goog-api-key"); got != "test-key" {
+		t.Fatalf("unexpected x-goog-api-key: %q", got)
+	}
+
+	if body.SystemInstruction == nil || body.SystemInstruction.Parts[0].Text != "You are terse.\n\nAnswer in English." {
+		t.Fatalf("unexpected systemInstruction: %+v", body.SystemInstruction)
+	}
+	if len(body.Contents) != 3 || body.Contents[0].Role != "user" || body.Contents[1].Role != "model" || body.Contents[2].Role != "user" {
+		t.Fatalf("unexpected contents: %+v", body.Contents)
+	}
+	gc := body.GenerationConfig
+	if gc == nil || gc.MaxOutputTokens != 100 || *gc.Temperature != 0.5 || gc.StopSequences[0] != "END" {
+		t.Fatalf("unexpected generationConfig: %+v", gc)
+	}
+
+	if content != "Hello there! How can I help?\n" || result.Message != content {
+		t.Fatalf("content = %q, message = %q", content, result.Message)
+	}
+	if result.StopReason != llmstreamer.StopReasonEndTurn || result.RawStopReason != "STOP" {
+		t.Fatalf("unexpected stop reason: %+v", result)
+	}
+	if result.ResponseID != "bXxjZ5_xBbiI_uMP2ZqJ4Q4" || result.Model != "gemini-2.0-flash-001" {
+		t.Fatalf("unexpected metadata: %+v", result)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 9 || result.Usage.OutputTokens != 8 || result.Usage.Cost == 0 {
+		t.Fatalf("unexpected usage: %+v", result.Usage)
+	}
+}
+
+func TestStreamChat_FunctionCall(t *testing.T) {
+	var body RequestBody
+	s := serveFixture(t, "function_call.sse", nil, &body)
+
+	tool := llmstreamer.Tool{Name: "get_weather", Description: "Current weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+
+	var deltas string
+	var calls []llmstreamer.ToolCall
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "Weather in Paris?"}}, &llmstreamer.StreamCallbacks{
+		OnToolCallDelta: func(id, d string) { deltas += d },
+		OnToolCall:      func(c llmstreamer.ToolCall) { calls = append(calls, c) },
+		OnFinishResult:  func(r llmstreamer.FinishResult) { result = r },
+		OnError:         func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}, llmstreamer.WithTools(tool), llmstreamer.WithForcedTool("get_weather"))
+
+	if len(body.Tools) != 1 || body.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Fatalf("unexpected tools: %+v", body.Tools)
+	}
+	fc := body.ToolConfig.FunctionCallingConfig
+	if fc.Mode != "ANY" || len(fc.AllowedFunctionNames) != 1 || fc.AllowedFunctionNames[0] != "get_weather" {
+		t.Fatalf("unexpected tool config: %+v", fc)
+	}
+
+	if len(calls) != 1 || calls[0].Name != "get_weather" || calls[0].Arguments != `{"city": "Paris"}` || deltas != calls[0].Arguments {
+		t.Fatalf("unexpected calls: %+v (deltas %q)", calls, deltas)
+	}
+	if !strings.HasPrefix(calls[0].ID, generatedIDPrefix) {
+		t.Fatalf("expected a generated call ID, got %q", calls[0].ID)
+	}
+	if result.StopReason != llmstreamer.StopReasonToolUse || len(result.ToolCalls) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestStreamChat_SafetyFinishReason(t *testing.T) {
+	s := serveFixture(t, "safety.sse", nil, nil)
+
+	content, result := collect(t, s, []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "..."}})
+
+	if content != "Here is how" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+	if result.StopReason != llmstreamer.StopReasonContentFilter || result.RawStopReason != "SAFETY" {
+		t.Fatalf("unexpected stop reason: %+v", result)
+	}
+}
+
+func TestStreamChat_PromptBlocked(t *testing.T) {
+	s := serveFixture(t, "prompt_blocked.sse", nil, nil)
+
+	content, result := collect(t, s, []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "..."}})
+
+	if content != "" || result.Message != "" {
+		t.Fatalf("expected no content, got %q", content)
+	}
+	if result.StopReason != llmstreamer.StopReasonContentFilter || result.RawStopReason != "SAFETY" {
+		t.Fatalf("unexpected stop reason: %+v", result)
+	}
+}
+
+func TestStreamChat_Thinking(t *testing.T) {
+	s := serveFixture(t, "thinking.sse", nil, nil)
+
+	var reasoning string
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "2+2?"}}, &llmstreamer.StreamCallbacks{
+		OnReasoning:    func(r string) { reasoning += r },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if reasoning != "The user asks for 2+2." || result.Reasoning != reasoning || result.Message != "4" {
+		t.Fatalf("reasoning = %q, result = %+v", reasoning, result)
+	}
+	u := result.Usage
+	if u == nil || u.InputTokens != 3 || u.CacheReadTokens != 4 || u.OutputTokens != 25 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+}
+
+func TestStreamChat_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error":{"code":429,"message":"You exceeded your current quota.","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"53s"}]}}`)
+	}))
+	defer srv.Close()
+
+	s := New("test-key", "")
+	s.BaseURL = srv.URL
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{OnError: func(err error) { gotErr = err }})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Provider != "gemini" || apiErr.Type != "RESOURCE_EXHAUSTED" || apiErr.RetryAfter != 53*time.Second {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+	if !errors.Is(gotErr, llmstreamer.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited")
+	}
+}
+
+func TestNewRequestBody_ToolRoundTrip(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleUser, Content: "Weather in Paris?"},
+		{Role: llmstreamer.RoleAssistant, ToolCalls: []llmstreamer.ToolCall{
+			{ID: generatedIDPrefix + "0", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+			{ID: "abc", Name: "get_time", Arguments: ""},
+		}},
+		llmstreamer.ToolResult(generatedIDPrefix+"0", "18°C"),
+		llmstreamer.ToolResult("abc", `{"time":"12:00"}`),
+	}
+
+	body, err := newRequestBody(messages, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("newRequestBody returned error: %v", err)
+	}
+	if len(body.Contents) != 3 {
+		t.Fatalf("expected 3 contents, got %+v", body.Contents)
+	}
+
+	model := body.Contents[1]
+	if model.Role != "model" || model.Parts[0].FunctionCall.ID != "" || model.Parts[1].FunctionCall.ID != "abc" || string(model.Parts[1].FunctionCall.Args) != "{}" {
+		t.Fatalf("unexpected model turn: %+v", model)
+	}
+
+	results := body.Contents[2]
+	if results.Role != "user" || len(results.Parts) != 2 {
+		t.Fatalf("unexpected function responses: %+v", results)
+	}
+	first, second := results.Parts[0].FunctionResponse, results.Parts[1].FunctionResponse
+	if first.Name != "get_weather" || string(first.Response) != `{"content":"18°C"}` {
+		t.Fatalf("unexpected first response: %+v", first)
+	}
+	if second.Name != "get_time" || second.ID != "abc" || string(second.Response) != `{"time":"12:00"}` {
+		t.Fatalf("unexpected second response: %+v", second)
+	}
+}
+
+func TestNewRequestBody_ResponseFormat(t *testing.T) {
+	rf := llmstreamer.ResponseFormat{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`)}
+	body, err := newRequestBody(nil, llmstreamer.NewOptions(llmstreamer.WithResponseFormat(rf)))
+	if err != nil {
+		t.Fatalf("newRequestBody returned error: %v", err)
+	}
+	if body.GenerationConfig.ResponseMimeType != "application/json" || string(body.GenerationConfig.ResponseJSONSchema) != `{"type":"object"}` {
+		t.Fatalf("unexpected generationConfig: %+v", body.GenerationConfig)
+	}
+}
+
+func TestStreamChat_InvalidOptions(t *testing.T) {
+	var gotErr error
+	New("test-key", "").StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	}, llmstreamer.WithTemperature(3))
+
+	if gotErr == nil {
+		t.Fatalf("expected an error for temperature above 2")
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	if _, ok := PriceFor("gemini-1.5-flash-002"); !ok {
+		t.Fatalf("expected a price for a versioned model")
+	}
+	if _, ok := PriceFor("models/gemini-2.0-flash"); !ok {
+		t.Fatalf("expected a price for a resource name")
+	}
+	if _, ok := PriceFor("gemini-ultra"); ok {
+		t.Fatalf("expected no price for an unknown model")
+	}
+}
+
+func TestSetPrice(t *testing.T) {
+	want := llmstreamer.Price{Input: 0.05, Output: 0.20}
+	SetPrice("gemini-tuned", want)
+
+	if p, ok := PriceFor("models/gemini-tuned-001"); !ok || p != want {
+		t.Fatalf("expected the price set by SetPrice, got %+v %v", p, ok)
+	}
+}
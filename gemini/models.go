@@ -0,0 +1,14 @@
+package gemini
+
+type Model string
+
+const (
+	ModelGemini25Pro   Model = "gemini-2.5-pro"
+	ModelGemini25Flash Model = "gemini-2.5-flash"
+
+	ModelGemini20Flash     Model = "gemini-2.0-flash"
+	ModelGemini20FlashLite Model = "gemini-2.0-flash-lite"
+
+	ModelGemini15Pro   Model = "gemini-1.5-pro"
+	ModelGemini15Flash Model = "gemini-1.5-flash"
+)
@@ -0,0 +1,54 @@
+package gemini
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// prices holds the list prices used to compute Usage.Cost, for prompts up to
+// the models' lower context tier. It is read by every stream, so changes go
+// through SetPrice.
+var (
+	pricesMu sync.RWMutex
+	prices   = map[Model]llmstreamer.Price{
+		ModelGemini25Pro:       {Input: 1.25, Output: 10.00, CacheRead: 0.31},
+		ModelGemini25Flash:     {Input: 0.30, Output: 2.50, CacheRead: 0.075},
+		ModelGemini20Flash:     {Input: 0.10, Output: 0.40, CacheRead: 0.025},
+		ModelGemini20FlashLite: {Input: 0.075, Output: 0.30},
+		ModelGemini15Pro:       {Input: 1.25, Output: 5.00, CacheRead: 0.3125},
+		ModelGemini15Flash:     {Input: 0.075, Output: 0.30, CacheRead: 0.01875},
+	}
+)
+
+// PriceFor looks up the price of a model. Versioned names such as
+// "gemini-1.5-flash-002" match the longest model name they start with.
+func PriceFor(model string) (llmstreamer.Price, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	model = strings.TrimPrefix(model, "models/")
+	if p, ok := prices[Model(model)]; ok {
+		return p, true
+	}
+
+	var best Model
+	for m := range prices {
+		if strings.HasPrefix(model, string(m)+"-") && len(m) > len(best) {
+			best = m
+		}
+	}
+	if best == "" {
+		return llmstreamer.Price{}, false
+	}
+	return prices[best], true
+}
+
+// SetPrice adds or replaces the price of a model, for example to match
+// negotiated rates. It is safe to call while streams are running.
+func SetPrice(model Model, price llmstreamer.Price) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices[model] = price
+}
@@ -0,0 +1,310 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+const (
+	roleUser  = "user"
+	roleModel = "model"
+)
+
+type RequestBody struct {
+	Contents          []Content         `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig       `json:"toolConfig,omitempty"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type GenerationConfig struct {
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"topP,omitempty"`
+	TopK               *int            `json:"topK,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	Seed               *int64          `json:"seed,omitempty"`
+	PresencePenalty    *float64        `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64        `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+type FunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type ToolConfig struct {
+	FunctionCallingConfig FunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+func newRequestBody(messages []llmstreamer.Message, o llmstreamer.Options) (RequestBody, error) {
+	if err := validateOptions(o); err != nil {
+		return RequestBody{}, err
+	}
+
+	system, turns := splitSystem(messages)
+
+	body := RequestBody{
+		Contents:          translateMessages(turns),
+		SystemInstruction: system,
+		Tools:             translateTools(o.Tools),
+		ToolConfig:        translateToolChoice(o.ToolChoice),
+	}
+
+	body.GenerationConfig = &GenerationConfig{
+		MaxOutputTokens:  o.MaxTokens,
+		Temperature:      o.Temperature,
+		TopP:             o.TopP,
+		TopK:             o.TopK,
+		StopSequences:    o.Stop,
+		Seed:             o.Seed,
+		PresencePenalty:  o.PresencePenalty,
+		FrequencyPenalty: o.FrequencyPenalty,
+	}
+	if rf := o.ResponseFormat; rf != nil {
+		body.GenerationConfig.ResponseMimeType = "application/json"
+		body.GenerationConfig.ResponseJSONSchema = rf.Schema
+	}
+
+	return body, nil
+}
+
+// splitSystem moves system and developer messages into the systemInstruction
+// field. Multiple instruction messages are joined in order.
+func splitSystem(messages []llmstreamer.Message) (*Content, []llmstreamer.Message) {
+	var system []string
+	turns := make([]llmstreamer.Message, 0, len(messages))
+
+	for _, m := range messages {
+		if m.IsInstruction() {
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		}
+		turns = append(turns, m)
+	}
+
+	if len(system) == 0 {
+		return nil, turns
+	}
+	return &Content{Parts: []Part{{Text: strings.Join(system, "\n\n")}}}, turns
+}
+
+// translateMessages converts messages to contents. Assistant messages use the
+// "model" role, tool results become functionResponse parts of a user turn,
+// and consecutive messages with the same role are merged because the API
+// requires user and model turns to alternate.
+func translateMessages(messages []llmstreamer.Message) []Content {
+	out := make([]Content, 0, len(messages))
+	// Function responses are matched to calls by name, so remember the name
+	// of every call made so far.
+	names := make(map[string]string)
+
+	for _, m := range messages {
+		role := roleUser
+		var parts []Part
+
+		switch m.Role {
+		case llmstreamer.RoleTool:
+			parts = append(parts, Part{FunctionResponse: &FunctionResponse{
+				ID:       callID(m.ToolCallID),
+				Name:     names[m.ToolCallID],
+				Response: functionResponse(m.Content),
+			}})
+		case llmstreamer.RoleAssistant:
+			role = roleModel
+			fallthrough
+		default:
+			if m.Content != "" {
+				parts = append(parts, Part{Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				names[tc.ID] = tc.Name
+				args := json.RawMessage(tc.Arguments)
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, Part{FunctionCall: &FunctionCall{ID: callID(tc.ID), Name: tc.Name, Args: args}})
+			}
+		}
+
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Parts = append(out[n-1].Parts, parts...)
+			continue
+		}
+		out = append(out, Content{Role: role, Parts: parts})
+	}
+	return out
+}
+
+// generatedIDPrefix marks the call IDs the client makes up for function calls
+// that the API returned without one. They are not sent back.
+const generatedIDPrefix = "gemini_call_"
+
+func callID(id string) string {
+	if strings.HasPrefix(id, generatedIDPrefix) {
+		return ""
+	}
+	return id
+}
+
+// functionResponse wraps a tool result in the JSON object the API expects.
+// Results that already are a JSON object are sent as they are.
+func functionResponse(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	b, _ := json.Marshal(map[string]string{"content": content})
+	return b
+}
+
+func translateTools(tools []llmstreamer.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	decls := make([]FunctionDeclaration, len(tools))
+	for i, t := range tools {
+		decls[i] = FunctionDeclaration{Name: t.Name, Description: t.Description, ParametersJSONSchema: t.Parameters}
+	}
+	return []Tool{{FunctionDeclarations: decls}}
+}
+
+func translateToolChoice(choice *llmstreamer.ToolChoice) *ToolConfig {
+	if choice == nil {
+		return nil
+	}
+
+	config := FunctionCallingConfig{}
+	switch choice.Mode {
+	case llmstreamer.ToolChoiceNone:
+		config.Mode = "NONE"
+	case llmstreamer.ToolChoiceRequired:
+		config.Mode = "ANY"
+	case llmstreamer.ToolChoiceTool:
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{choice.Name}
+	default:
+		config.Mode = "AUTO"
+	}
+	return &ToolConfig{FunctionCallingConfig: config}
+}
+
+func validateOptions(o llmstreamer.Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if o.Temperature != nil && *o.Temperature > 2 {
+		return fmt.Errorf("gemini: temperature must be between 0 and 2, got %v", *o.Temperature)
+	}
+	if len(o.Stop) > 5 {
+		return fmt.Errorf("gemini: at most 5 stop sequences are supported, got %d", len(o.Stop))
+	}
+	return nil
+}
+
+type StreamEvent struct {
+	Candidates     []Candidate     `json:"candidates,omitempty"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+	ModelVersion   string          `json:"modelVersion,omitempty"`
+	ResponseID     string          `json:"responseId,omitempty"`
+	// Error is set when the server fails after the stream has started.
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+type Candidate struct {
+	Content       *Content       `json:"content,omitempty"`
+	FinishReason  string         `json:"finishReason,omitempty"`
+	Index         int            `json:"index"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type PromptFeedback struct {
+	BlockReason   string         `json:"blockReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// toUsage converts the counts, which every chunk reports cumulatively. The
+// prompt count includes cached tokens and thinking tokens are billed as
+// output.
+func (u UsageMetadata) toUsage(model string) llmstreamer.Usage {
+	usage := llmstreamer.Usage{
+		InputTokens:     u.PromptTokenCount - u.CachedContentTokenCount,
+		OutputTokens:    u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		CacheReadTokens: u.CachedContentTokenCount,
+	}
+	if price, ok := PriceFor(model); ok {
+		usage.Cost = price.Cost(usage)
+	}
+	return usage
+}
+
+func stopReason(reason string) llmstreamer.StopReason {
+	switch reason {
+	case "STOP":
+		return llmstreamer.StopReasonEndTurn
+	case "MAX_TOKENS":
+		return llmstreamer.StopReasonMaxTokens
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return llmstreamer.StopReasonContentFilter
+	}
+	return llmstreamer.StopReasonOther
+}
@@ -0,0 +1,288 @@
+// Package jsonscan reads JSON values without reflection. It is used on the
+// streaming hot path to decode the handful of chunk shapes that make up almost
+// all of a response. It accepts only input that encoding/json decodes the same
+// way and reports anything else as a failure, so callers can fall back to
+// encoding/json without changing behavior.
+package jsonscan
+
+import (
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Scanner reads one JSON document. The zero value is ready to use after Reset.
+type Scanner struct {
+	data []byte
+	pos  int
+	// buf holds unescaped strings. It is reused between reads.
+	buf []byte
+}
+
+// Reset starts reading data.
+func (s *Scanner) Reset(data []byte) {
+	s.data, s.pos = data, 0
+}
+
+// End reports whether only whitespace is left.
+func (s *Scanner) End() bool {
+	s.skipSpace()
+	return s.pos == len(s.data)
+}
+
+// Object reads an object, calling field with each key. field must read the
+// value and report whether it could; returning false stops the scan. The key
+// is only valid until the next read.
+func (s *Scanner) Object(field func(key []byte) bool) bool {
+	if !s.consume('{') {
+		return false
+	}
+	if s.consume('}') {
+		return true
+	}
+	for {
+		key, ok := s.String()
+		if !ok || !s.consume(':') || !field(key) {
+			return false
+		}
+		if s.consume('}') {
+			return true
+		}
+		if !s.consume(',') {
+			return false
+		}
+	}
+}
+
+// Array reads an array, calling elem for each element. elem must read the
+// element and report whether it could; returning false stops the scan.
+func (s *Scanner) Array(elem func() bool) bool {
+	if !s.consume('[') {
+		return false
+	}
+	if s.consume(']') {
+		return true
+	}
+	for {
+		if !elem() {
+			return false
+		}
+		if s.consume(']') {
+			return true
+		}
+		if !s.consume(',') {
+			return false
+		}
+	}
+}
+
+// Null reads a null if one is next.
+func (s *Scanner) Null() bool {
+	s.skipSpace()
+	if len(s.data)-s.pos >= 4 && string(s.data[s.pos:s.pos+4]) == "null" {
+		s.pos += 4
+		return true
+	}
+	return false
+}
+
+// Bool reads true or false.
+func (s *Scanner) Bool() (value, ok bool) {
+	s.skipSpace()
+	switch rest := s.data[s.pos:]; {
+	case len(rest) >= 4 && string(rest[:4]) == "true":
+		s.pos += 4
+		return true, true
+	case len(rest) >= 5 && string(rest[:5]) == "false":
+		s.pos += 5
+		return false, true
+	}
+	return false, false
+}
+
+// Int reads an integer that fits in an int64 without a fraction or exponent.
+func (s *Scanner) Int() (int64, bool) {
+	s.skipSpace()
+	i := s.pos
+	neg := i < len(s.data) && s.data[i] == '-'
+	if neg {
+		i++
+	}
+	start := i
+	var n int64
+	for ; i < len(s.data) && '0' <= s.data[i] && s.data[i] <= '9'; i++ {
+		// Eighteen digits always fit; longer numbers are left to encoding/json.
+		if i-start == 18 {
+			return 0, false
+		}
+		n = n*10 + int64(s.data[i]-'0')
+	}
+	if i == start || s.data[start] == '0' && i-start > 1 {
+		return 0, false
+	}
+	if i < len(s.data) && (s.data[i] == '.' || s.data[i] == 'e' || s.data[i] == 'E') {
+		return 0, false
+	}
+	s.pos = i
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// String reads a string and returns it unescaped. The result is only valid
+// until the next read. Strings with invalid UTF-8 or unpaired surrogates,
+// which encoding/json replaces with U+FFFD, are reported as failures.
+func (s *Scanner) String() ([]byte, bool) {
+	if !s.consume('"') {
+		return nil, false
+	}
+	ascii := true
+	for i := s.pos; i < len(s.data); i++ {
+		switch c := s.data[i]; {
+		case c == '"':
+			b := s.data[s.pos:i]
+			if !ascii && !utf8.Valid(b) {
+				return nil, false
+			}
+			s.pos = i + 1
+			return b, true
+		case c == '\\':
+			return s.unescape()
+		case c < 0x20:
+			return nil, false
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+	return nil, false
+}
+
+// ReadString reads a string or null into dst. A null leaves dst unchanged, as
+// encoding/json does. If the string equals prev, prev is stored instead, so
+// values that repeat in every chunk, such as the response ID, are not
+// allocated again.
+func (s *Scanner) ReadString(dst *string, prev string) bool {
+	if s.Null() {
+		return true
+	}
+	b, ok := s.String()
+	if !ok {
+		return false
+	}
+	if string(b) == prev {
+		*dst = prev
+	} else {
+		*dst = string(b)
+	}
+	return true
+}
+
+func (s *Scanner) unescape() ([]byte, bool) {
+	buf := s.buf[:0]
+	i := s.pos
+	for i < len(s.data) {
+		c := s.data[i]
+		switch {
+		case c == '"':
+			s.buf = buf
+			if !utf8.Valid(buf) {
+				return nil, false
+			}
+			s.pos = i + 1
+			return buf, true
+		case c < 0x20:
+			return nil, false
+		case c != '\\':
+			buf = append(buf, c)
+			i++
+			continue
+		}
+
+		if i+1 == len(s.data) {
+			return nil, false
+		}
+		switch e := s.data[i+1]; e {
+		case '"', '\\', '/':
+			buf = append(buf, e)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, ok := hex4(s.data[i+2:])
+			if !ok {
+				return nil, false
+			}
+			if utf16.IsSurrogate(r) {
+				rest := s.data[i+6:]
+				if len(rest) < 2 || rest[0] != '\\' || rest[1] != 'u' {
+					return nil, false
+				}
+				r2, ok := hex4(rest[2:])
+				if !ok {
+					return nil, false
+				}
+				if r = utf16.DecodeRune(r, r2); r == unicode.ReplacementChar {
+					return nil, false
+				}
+				i += 6
+			}
+			buf = utf8.AppendRune(buf, r)
+			i += 6
+			continue
+		default:
+			return nil, false
+		}
+		i += 2
+	}
+	s.buf = buf
+	return nil, false
+}
+
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+func (s *Scanner) consume(c byte) bool {
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *Scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package jsonscan
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	inputs := []string{
+		`""`,
+		`"plain"`,
+		` "spaced" `,
+		`"café café"`,
+		`"line\nbreak\ttab \"quoted\" back\\slash \/ \b\f\r"`,
+		`"😀 emoji"`,
+		`"éé"`,
+		// Inputs that encoding/json rejects or repairs are failures.
+		`"\ud83d alone"`,
+		`"\ude00"`,
+		"\"bad \xff utf8\"",
+		"\"control \x01\"",
+		`"\x41"`,
+		`"\u12"`,
+		`"unterminated`,
+		`"escaped unterminated\n`,
+		`plain`,
+	}
+
+	for _, in := range inputs {
+		var s Scanner
+		s.Reset([]byte(in))
+		got, ok := s.String()
+		ok = ok && s.End()
+
+		var want string
+		err := json.Unmarshal([]byte(in), &want)
+		if ok {
+			if err != nil || string(got) != want {
+				t.Errorf("String(%q) = %q, encoding/json got %q, %v", in, got, want, err)
+			}
+		} else if err == nil && json.Valid([]byte(in)) && !repaired(in) {
+			t.Errorf("String(%q) failed, encoding/json got %q", in, want)
+		}
+	}
+}
+
+// repaired reports whether encoding/json accepts in only by replacing
+// characters with U+FFFD.
+func repaired(in string) bool {
+	switch in {
+	case `"\ud83d alone"`, `"\ude00"`, "\"bad \xff utf8\"":
+		return true
+	}
+	return false
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{`0`, 0, true},
+		{`-12`, -12, true},
+		{` 1741569952`, 1741569952, true},
+		{`999999999999999999`, 999999999999999999, true},
+		{`9999999999999999999`, 0, false},
+		{`01`, 0, false},
+		{`1.0`, 0, false},
+		{`1e3`, 0, false},
+		{`-`, 0, false},
+		{`"1"`, 0, false},
+	}
+
+	for _, tt := range tests {
+		var s Scanner
+		s.Reset([]byte(tt.in))
+		got, ok := s.Int()
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Int(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestObject(t *testing.T) {
+	var s Scanner
+	s.Reset([]byte(`{"id":"a", "n": 2, "list": [null, null], "empty": {}, "ok": true}`))
+
+	var id string
+	var n int64
+	var b bool
+	nulls := 0
+	ok := s.Object(func(key []byte) bool {
+		switch string(key) {
+		case "id":
+			return s.ReadString(&id, "")
+		case "n":
+			var ok bool
+			n, ok = s.Int()
+			return ok
+		case "list":
+			return s.Array(func() bool {
+				nulls++
+				return s.Null()
+			})
+		case "empty":
+			return s.Object(func([]byte) bool { return false })
+		case "ok":
+			var ok bool
+			b, ok = s.Bool()
+			return ok
+		}
+		return false
+	})
+	if !ok || !s.End() || id != "a" || n != 2 || nulls != 2 || !b {
+		t.Fatalf("Object() = %v: id %q, n %d, nulls %d, ok %v", ok, id, n, nulls, b)
+	}
+
+	s.Reset([]byte(`{"id":"a" "n":2}`))
+	if s.Object(func([]byte) bool { return s.ReadString(&id, "") }) {
+		t.Fatal("accepted a missing comma")
+	}
+}
+
+func TestReadString_Interns(t *testing.T) {
+	prev := string([]byte("chatcmpl-1"))
+	data := []byte(`"chatcmpl-1"`)
+	var s Scanner
+	s.Reset(data)
+
+	var got string
+	if !s.ReadString(&got, prev) || got != prev {
+		t.Fatalf("ReadString() = %q", got)
+	}
+	if testing.AllocsPerRun(100, func() {
+		s.Reset(data)
+		s.ReadString(&got, prev)
+	}) != 0 {
+		t.Fatal("ReadString allocated for a repeated value")
+	}
+}
@@ -0,0 +1,213 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Location struct {
+	City    string `json:"city" description:"City name" jsonschema:"required,minLength=1"`
+	Country string `json:"country,omitempty"`
+}
+
+type Forecast struct {
+	Location
+	Units    string            `json:"units" jsonschema:"enum=metric|imperial"`
+	Days     int               `json:"days" jsonschema:"required,minimum=1,maximum=7"`
+	Hours    []uint            `json:"hours,omitempty" jsonschema:"maxItems=3"`
+	Level    int               `json:"level" jsonschema:"enum=1|2|3"`
+	Since    *time.Time        `json:"since,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Extra    json.RawMessage   `json:"extra,omitempty"`
+	Internal string            `json:"-"`
+	hidden   string
+}
+
+func TestFor_Struct(t *testing.T) {
+	s, err := For[Forecast]()
+	if err != nil {
+		t.Fatalf("For returned error: %v", err)
+	}
+
+	if s.Type != "object" || s.AdditionalProperties != false {
+		t.Fatalf("unexpected root schema: %+v", s)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"city", "days"}) {
+		t.Fatalf("unexpected required: %v", s.Required)
+	}
+	for _, name := range []string{"Internal", "hidden", "Location"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Fatalf("property %q should not be generated", name)
+		}
+	}
+
+	city := s.Properties["city"]
+	if city.Type != "string" || city.Description != "City name" || city.MinLength == nil || *city.MinLength != 1 {
+		t.Fatalf("unexpected city schema: %+v", city)
+	}
+	if units := s.Properties["units"]; !reflect.DeepEqual(units.Enum, []interface{}{"metric", "imperial"}) {
+		t.Fatalf("unexpected units enum: %v", units.Enum)
+	}
+	if level := s.Properties["level"]; !reflect.DeepEqual(level.Enum, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Fatalf("unexpected level enum: %v", level.Enum)
+	}
+	days := s.Properties["days"]
+	if days.Type != "integer" || *days.Minimum != 1 || *days.Maximum != 7 {
+		t.Fatalf("unexpected days schema: %+v", days)
+	}
+	hours := s.Properties["hours"]
+	if hours.Type != "array" || hours.Items.Type != "integer" || *hours.Items.Minimum != 0 || *hours.MaxItems != 3 {
+		t.Fatalf("unexpected hours schema: %+v", hours)
+	}
+	if since := s.Properties["since"]; since.Type != "string" || since.Format != "date-time" {
+		t.Fatalf("unexpected since schema: %+v", since)
+	}
+	if tags := s.Properties["tags"]; tags.Type != "object" || tags.AdditionalProperties.(*Schema).Type != "string" {
+		t.Fatalf("unexpected tags schema: %+v", tags)
+	}
+	if extra := s.Properties["extra"]; extra.Type != "" {
+		t.Fatalf("raw JSON should accept anything: %+v", extra)
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(b), `"additionalProperties":false`) || !strings.Contains(string(b), `"minLength":1`) {
+		t.Fatalf("unexpected JSON: %s", b)
+	}
+}
+
+// EmbedsA and EmbedsB embed each other, which function-local types cannot.
+type EmbedsA struct {
+	*EmbedsB
+}
+
+type EmbedsB struct {
+	*EmbedsA
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	type node struct {
+		Next *node `json:"next"`
+	}
+	type SelfEmbedded struct {
+		*SelfEmbedded
+		X int `json:"x"`
+	}
+	type badRule struct {
+		X int `json:"x" jsonschema:"minimum=abc"`
+	}
+	type unknownRule struct {
+		X int `json:"x" jsonschema:"pattern=.*"`
+	}
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(node{}),
+		reflect.TypeOf(SelfEmbedded{}),
+		reflect.TypeOf(EmbedsA{}),
+		reflect.TypeOf(badRule{}),
+		reflect.TypeOf(unknownRule{}),
+		reflect.TypeOf(map[int]string{}),
+		reflect.TypeOf(make(chan int)),
+	} {
+		if _, err := Generate(typ); err == nil {
+			t.Fatalf("expected error for %s", typ)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	got, err := Decode[Forecast]([]byte(`{"city":"Paris","days":3,"units":"metric","hours":[1,2]}`))
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if got.City != "Paris" || got.Days != 3 || got.Units != "metric" || len(got.Hours) != 2 {
+		t.Fatalf("unexpected value: %+v", got)
+	}
+}
+
+func TestDecode_Bytes(t *testing.T) {
+	type Blob struct {
+		Data []byte
+	}
+
+	s, err := For[Blob]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := s.Properties["Data"]; p.Type != "string" || p.ContentEncoding != "base64" {
+		t.Fatalf("unexpected schema for []byte: %+v", p)
+	}
+
+	data, err := json.Marshal(Blob{Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode[Blob](data)
+	if err != nil || string(got.Data) != "hello" {
+		t.Fatalf("Decode(%s) = %q, %v", data, got.Data, err)
+	}
+
+	var ve *ValidationError
+	if _, err := Decode[Blob]([]byte(`{"Data":"not base64!"}`)); !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestDecode_ValidationErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing required": `{"days":3}`,
+		"empty string":     `{"city":"","days":3}`,
+		"below minimum":    `{"city":"Paris","days":0}`,
+		"above maximum":    `{"city":"Paris","days":8}`,
+		"not an integer":   `{"city":"Paris","days":1.5}`,
+		"wrong type":       `{"city":"Paris","days":"3"}`,
+		"enum":             `{"city":"Paris","days":3,"units":"kelvin"}`,
+		"numeric enum":     `{"city":"Paris","days":3,"level":4}`,
+		"too many items":   `{"city":"Paris","days":3,"hours":[1,2,3,4]}`,
+		"negative uint":    `{"city":"Paris","days":3,"hours":[-1]}`,
+		"unknown property": `{"city":"Paris","days":3,"color":"red"}`,
+		"not an object":    `[]`,
+	}
+
+	for name, data := range tests {
+		_, err := Decode[Forecast]([]byte(data))
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("%s: expected ValidationError, got %v", name, err)
+		}
+	}
+
+	if _, err := Decode[Forecast]([]byte(`{"city":`)); err == nil {
+		t.Fatalf("expected error for invalid JSON")
+	}
+}
+
+func TestValidate_ErrorPath(t *testing.T) {
+	type Outer struct {
+		Items []Location `json:"items"`
+	}
+
+	s, err := For[Outer]()
+	if err != nil {
+		t.Fatalf("For returned error: %v", err)
+	}
+
+	err = s.Validate([]byte(`{"items":[{"city":"a"},{"country":"b"}]}`))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Path != "$.items[1]" {
+		t.Fatalf("expected error at $.items[1], got %v", err)
+	}
+}
+
+func TestValidate_NullAllowed(t *testing.T) {
+	s, _ := For[Forecast]()
+	if err := s.Validate([]byte(`{"city":"Paris","days":3,"since":null}`)); err != nil {
+		t.Fatalf("expected null to be accepted, got %v", err)
+	}
+}
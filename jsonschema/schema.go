@@ -0,0 +1,254 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema understood by tool-calling and
+// structured-output APIs.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// For returns the schema of T.
+func For[T any]() (*Schema, error) {
+	return Generate(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Generate builds a schema from a Go type. Struct fields are named after their
+// json tag and may be annotated with a description tag and a jsonschema tag
+// holding comma separated rules:
+//
+//	type Query struct {
+//		City  string `json:"city" description:"City name" jsonschema:"required,minLength=1"`
+//		Units string `json:"units" jsonschema:"enum=metric|imperial"`
+//		Days  int    `json:"days" jsonschema:"minimum=1,maximum=7"`
+//	}
+//
+// Supported rules are required, enum, minimum, maximum, minLength, maxLength,
+// minItems, maxItems and format.
+func Generate(t reflect.Type) (*Schema, error) {
+	return generate(t, map[reflect.Type]bool{})
+}
+
+func generate(t reflect.Type, seen map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case rawType:
+		return &Schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		// encoding/json sends byte slices as base64 strings.
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+		}
+		items, err := generate(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("jsonschema: unsupported map key type %s", t.Key())
+		}
+		values, err := generate(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("jsonschema: recursive type %s is not supported", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := &Schema{
+			Type:                 "object",
+			Properties:           map[string]*Schema{},
+			AdditionalProperties: false,
+		}
+		if err := addFields(s, t, seen); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("jsonschema: unsupported type %s", t)
+}
+
+func addFields(s *Schema, t reflect.Type, seen map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if seen[ft] {
+					return fmt.Errorf("jsonschema: recursive type %s is not supported", ft)
+				}
+				seen[ft] = true
+				err := addFields(s, ft, seen)
+				delete(seen, ft)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, err := generate(f.Type, seen)
+		if err != nil {
+			return fmt.Errorf("jsonschema: field %s: %w", f.Name, err)
+		}
+		prop.Description = f.Tag.Get("description")
+
+		required, err := applyRules(prop, f.Tag.Get("jsonschema"))
+		if err != nil {
+			return fmt.Errorf("jsonschema: field %s: %w", f.Name, err)
+		}
+
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+func applyRules(s *Schema, tag string) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				ev, err := enumValue(s.Type, v)
+				if err != nil {
+					return false, err
+				}
+				s.Enum = append(s.Enum, ev)
+			}
+		case "minimum":
+			s.Minimum, err = parseFloat(key, value)
+		case "maximum":
+			s.Maximum, err = parseFloat(key, value)
+		case "minLength":
+			s.MinLength, err = parseInt(key, value)
+		case "maxLength":
+			s.MaxLength, err = parseInt(key, value)
+		case "minItems":
+			s.MinItems, err = parseInt(key, value)
+		case "maxItems":
+			s.MaxItems, err = parseInt(key, value)
+		case "format":
+			s.Format = value
+		case "":
+		default:
+			return false, fmt.Errorf("unknown rule %q", key)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return required, nil
+}
+
+func enumValue(typ string, v string) (interface{}, error) {
+	switch typ {
+	case "integer":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer enum value %q", v)
+		}
+		return n, nil
+	case "number":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number enum value %q", v)
+		}
+		return n, nil
+	}
+	return v, nil
+}
+
+func parseFloat(key, v string) (*float64, error) {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return &n, nil
+}
+
+func parseInt(key, v string) (*int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return &n, nil
+}
@@ -0,0 +1,190 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("jsonschema: %s: %s", e.Path, e.Message)
+}
+
+// Decode validates data against the schema of T and unmarshals it.
+func Decode[T any](data []byte) (T, error) {
+	var v T
+
+	s, err := For[T]()
+	if err != nil {
+		return v, err
+	}
+	if err := s.Validate(data); err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// Validate checks that data is a JSON document matching the schema. Null is
+// accepted for any property so optional pointer fields may be sent as null.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("jsonschema: invalid JSON: %w", err)
+	}
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("expected object")
+		}
+		return s.validateObject(path, obj)
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fail("expected array")
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fail("expected at least %d items, got %d", *s.MinItems, len(arr))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			return fail("expected at most %d items, got %d", *s.MaxItems, len(arr))
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("expected string")
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fail("expected at least %d characters, got %d", *s.MinLength, n)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("expected at most %d characters, got %d", *s.MaxLength, n)
+		}
+		if s.ContentEncoding == "base64" {
+			if _, err := base64.StdEncoding.DecodeString(str); err != nil {
+				return fail("expected base64")
+			}
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			return fail("expected %s", s.Type)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fail("invalid number %s", num)
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			return fail("expected integer, got %s", num)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be >= %v, got %s", *s.Minimum, num)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be <= %v, got %s", *s.Maximum, num)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("expected boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fail("value %v is not one of %v", v, s.Enum)
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		prop, ok := s.Properties[k]
+		if !ok {
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("unexpected property %q", k)}
+				}
+				continue
+			case *Schema:
+				prop = extra
+			default:
+				continue
+			}
+		}
+		if err := prop.validate(path+"."+k, obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		switch ev := e.(type) {
+		case string:
+			if s, ok := v.(string); ok && s == ev {
+				return true
+			}
+		case int64:
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil && i == ev {
+					return true
+				}
+			}
+		case float64:
+			if n, ok := v.(json.Number); ok {
+				if f, err := n.Float64(); err == nil && f == ev {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
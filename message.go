@@ -3,11 +3,28 @@ package llmstreamer
 type Role string
 
 const (
-	RoleUser  Role = "user"
-	RoleAdmin Role = "assistant"
+	RoleSystem    Role = "system"
+	RoleDeveloper Role = "developer"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+
+	// Deprecated: use RoleAssistant.
+	RoleAdmin = RoleAssistant
 )
 
 type Message struct {
 	Role    Role   `json:"role"`
 	Content string `json:"content"`
+
+	// ToolCalls are the calls requested by an assistant message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a RoleTool message to the call it answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// IsInstruction reports whether the message carries instructions for the model
+// (a system or developer prompt) rather than conversation turns.
+func (m Message) IsInstruction() bool {
+	return m.Role == RoleSystem || m.Role == RoleDeveloper
 }
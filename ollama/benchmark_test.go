@@ -0,0 +1,79 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// longStream builds a response of n single-token content lines.
+func longStream(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		token := " token"
+		if i%20 == 19 {
+			token = `.\n`
+		}
+		fmt.Fprintf(&b, `{"model":"llama3.2","created_at":"2024-07-22T20:33:28.123456Z","message":{"role":"assistant","content":"%s"},"done":false}`+"\n", token)
+	}
+	b.WriteString(`{"model":"llama3.2","created_at":"2024-07-22T20:33:29.123456Z","message":{"role":"assistant","content":""},"done_reason":"stop","done":true,"total_duration":4883583458,"load_duration":1334875,"prompt_eval_count":26,"prompt_eval_duration":342546000,"eval_count":100000,"eval_duration":4535599000}` + "\n")
+	return b.Bytes()
+}
+
+func BenchmarkProcessStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	cb := &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { n += len(c) },
+		OnError:   func(err error) { b.Fatal(err) },
+	}
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(stream))}
+		if err := processStream(resp, cb); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLegacyStream_100kTokens decodes the same response the way streams
+// were read before the scanner: a bufio.Scanner over the lines and
+// encoding/json for every line. It is the baseline for the benchmark above.
+func BenchmarkLegacyStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var message strings.Builder
+		sc := bufio.NewScanner(bytes.NewReader(stream))
+		for sc.Scan() {
+			var ev StreamEvent
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+				b.Fatal(err)
+			}
+			if ev.Message != nil {
+				message.WriteString(ev.Message.Content)
+				n += len(ev.Message.Content)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ollama
+
+import (
+	"encoding/json"
+
+	"github.com/alparslanyilmaaz/llmstreamer/internal/jsonscan"
+)
+
+// eventDecoder decodes stream lines. Every line but the last carries a bit of
+// content or thinking, so that shape is read with a scanner that reuses the
+// previous line's model and role; anything else goes through encoding/json.
+// The timestamp changes on every line, so it is gathered with the content and
+// thinking into buf and the three strings share one allocation. The decoded
+// event shares memory with the decoder and is only valid until the next call.
+type eventDecoder struct {
+	scan    jsonscan.Scanner
+	model   string
+	message Message
+	buf     []byte
+}
+
+func (d *eventDecoder) decode(data []byte) (StreamEvent, error) {
+	if ev, ok := d.decodeDelta(data); ok {
+		return ev, nil
+	}
+	var ev StreamEvent
+	err := json.Unmarshal(data, &ev)
+	return ev, err
+}
+
+// decodeDelta decodes data if it is an unfinished line with only text in its
+// message. It reports false, leaving the caller to use encoding/json, for any
+// other line.
+func (d *eventDecoder) decodeDelta(data []byte) (StreamEvent, bool) {
+	s := &d.scan
+	s.Reset(data)
+	d.buf = d.buf[:0]
+	var ev StreamEvent
+	var createdAt, content, thinking span
+
+	ok := s.Object(func(key []byte) bool {
+		switch string(key) {
+		case "model":
+			return s.ReadString(&ev.Model, d.model)
+		case "created_at":
+			return d.readSpan(&createdAt)
+		case "done":
+			done, ok := s.Bool()
+			return ok && !done
+		case "message":
+			if s.Null() {
+				ev.Message = nil
+				return true
+			}
+			if ev.Message == nil {
+				d.message = Message{}
+				ev.Message = &d.message
+			}
+			return s.Object(func(key []byte) bool {
+				switch string(key) {
+				case "role":
+					return s.ReadString(&d.message.Role, "assistant")
+				case "content":
+					return d.readSpan(&content)
+				case "thinking":
+					return d.readSpan(&thinking)
+				}
+				return false
+			})
+		}
+		return false
+	})
+	if !ok || !s.End() {
+		return StreamEvent{}, false
+	}
+	d.model = ev.Model
+
+	str := string(d.buf)
+	ev.CreatedAt = createdAt.of(str)
+	if ev.Message != nil {
+		d.message.Content = content.of(str)
+		d.message.Thinking = thinking.of(str)
+	}
+	return ev, true
+}
+
+// span is the position of a string in eventDecoder.buf.
+type span struct{ start, end int }
+
+func (p span) of(s string) string { return s[p.start:p.end] }
+
+// readSpan reads a string or null, appending the string to d.buf and
+// recording where it landed in *p.
+func (d *eventDecoder) readSpan(p *span) bool {
+	s := &d.scan
+	if s.Null() {
+		return true
+	}
+	b, ok := s.String()
+	if !ok {
+		return false
+	}
+	p.start = len(d.buf)
+	d.buf = append(d.buf, b...)
+	p.end = len(d.buf)
+	return true
+}
@@ -0,0 +1,37 @@
+package ollama
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEventDecoder(t *testing.T) {
+	lines := []struct {
+		data string
+		fast bool
+	}{
+		{`{"model":"llama3.2","created_at":"2024-07-22T20:33:28.123Z","message":{"role":"assistant","content":"The"},"done":false}`, true},
+		{`{"model":"llama3.2","created_at":"2024-07-22T20:33:28.124Z","message":{"role":"assistant","content":"","thinking":"hmm \"quoted\"\n"},"done":false}`, true},
+		{`{"model":"llama3.2","message":null,"done":false}`, true},
+		// Everything else is left to encoding/json.
+		{`{"model":"llama3.2","created_at":"2024-07-22T20:33:29Z","message":{"role":"assistant","content":""},"done_reason":"stop","done":true,"eval_count":290}`, false},
+		{`{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"add","arguments":{"a":1}}}]},"done":false}`, false},
+		{`{"error":"model not found"}`, false},
+		{`{"model":"llama3.2","done":tru}`, false},
+	}
+
+	var d eventDecoder
+	for _, l := range lines {
+		if _, ok := d.decodeDelta([]byte(l.data)); ok != l.fast {
+			t.Errorf("decodeDelta(%s) = %v, want %v", l.data, ok, l.fast)
+		}
+
+		got, gotErr := d.decode([]byte(l.data))
+		var want StreamEvent
+		wantErr := json.Unmarshal([]byte(l.data), &want)
+		if (gotErr != nil) != (wantErr != nil) || !reflect.DeepEqual(got, want) {
+			t.Errorf("decode(%s):\n got %+v, %v\nwant %+v, %v", l.data, got, gotErr, want, wantErr)
+		}
+	}
+}
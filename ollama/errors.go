@@ -0,0 +1,38 @@
+package ollama
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// ErrorBody is the error response. Ollama reports errors as a plain message
+// without a type or code.
+type ErrorBody struct {
+	Error string `json:"error"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "ollama",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Error == "" {
+		e.Body = string(body)
+		return e
+	}
+	e.Message = eb.Error
+	return e
+}
+
+// newStreamError converts an error line received after a 200 response.
+func newStreamError(message string) *llmstreamer.APIError {
+	return &llmstreamer.APIError{
+		Provider: "ollama",
+		Message:  message,
+	}
+}
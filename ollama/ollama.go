@@ -0,0 +1,324 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+const DefaultBaseURL = "http://localhost:11434"
+
+// OllamaStreamer streams chat responses from Ollama's native /api/chat
+// endpoint, which reports load and evaluation timings that the
+// OpenAI-compatible endpoint leaves out.
+type OllamaStreamer struct {
+	Model string
+	// ApiKey is sent as a bearer token, for servers behind an authenticating
+	// proxy. Local servers need none.
+	ApiKey string
+	// KeepAlive controls how long the model stays loaded after the request,
+	// as a duration such as "10m". "0s" unloads it right away and a negative
+	// duration keeps it loaded. Empty means the server default.
+	KeepAlive string
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces DefaultBaseURL, for servers on other hosts.
+	BaseURL string
+	// Header is added to every request. It overrides the headers set by the
+	// streamer.
+	Header http.Header
+}
+
+func New(model string) *OllamaStreamer {
+	return &OllamaStreamer{
+		Model: model,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*OllamaStreamer)(nil)
+	_ llmstreamer.Warmer   = (*OllamaStreamer)(nil)
+)
+
+func init() {
+	llmstreamer.Register("ollama", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		if cfg.Model == "" {
+			return nil, errors.New("ollama: Model is required")
+		}
+		s := New(cfg.Model)
+		s.ApiKey = cfg.APIKey
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		return s, nil
+	})
+}
+
+func (s *OllamaStreamer) StreamChat(
+	ctx context.Context,
+	messages []llmstreamer.Message,
+	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
+) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
+	if s.Model == "" {
+		cb.EmitError(errors.New("ollama: model is required"))
+		return
+	}
+
+	payload, err := newRequestBody(s.Model, messages, llmstreamer.NewOptions(opts...))
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+	payload.KeepAlive = s.KeepAlive
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, payload, cb)
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *OllamaStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *OllamaStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint("/"))
+}
+
+func (s *OllamaStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *OllamaStreamer) stream(ctx context.Context, payload RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, "/api/chat", payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
+}
+
+func (s *OllamaStreamer) prepareRequest(ctx context.Context, path string, payload interface{}) (*http.Client, *http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(path), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.ApiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	llmstreamer.SetHeaders(req.Header, s.Header)
+
+	client := s.client()
+
+	return client, req, nil
+}
+
+func (s *OllamaStreamer) endpoint(path string) string {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// processStream delivers the NDJSON response to cb, with the Metrics of the
+// response in FinishResult.Metadata. Errors that end the stream are returned
+// rather than emitted so the caller can decide whether to retry.
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
+		if err != nil {
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
+		}
+		return apiErr
+	}
+
+	reader := readerPool.Get().(*bufio.Reader)
+	reader.Reset(resp.Body)
+	defer func() {
+		reader.Reset(nil)
+		readerPool.Put(reader)
+	}()
+
+	var (
+		finalMessage strings.Builder
+		reasoning    strings.Builder
+		result       llmstreamer.FinishResult
+		long         []byte
+		events       eventDecoder
+	)
+
+	finish := func() {
+		if result.StopReason == llmstreamer.StopReasonEndTurn && len(result.ToolCalls) > 0 {
+			result.StopReason = llmstreamer.StopReasonToolUse
+		}
+		result.Message = finalMessage.String()
+		result.Reasoning = reasoning.String()
+		cb.EmitFinishResult(result)
+	}
+
+	for {
+		line, err := readLine(reader, &long)
+		if err != nil {
+			if err == io.EOF {
+				finish()
+				return nil
+			}
+			return fmt.Errorf("read failed: %w", err)
+		}
+
+		ev, err := events.decode(line)
+		if err != nil {
+			cb.EmitError(fmt.Errorf("failed to parse JSON: %w", err))
+			continue
+		}
+		if ev.Error != "" {
+			return newStreamError(ev.Error)
+		}
+
+		if ev.Model != "" {
+			result.Model = ev.Model
+		}
+
+		if m := ev.Message; m != nil {
+			if m.Thinking != "" {
+				reasoning.WriteString(m.Thinking)
+				cb.EmitReasoning(m.Thinking)
+			}
+			if m.Content != "" {
+				finalMessage.WriteString(m.Content)
+				cb.EmitContent(m.Content)
+			}
+			for _, tc := range m.ToolCalls {
+				call := llmstreamer.ToolCall{
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: string(tc.Function.Arguments),
+				}
+				if call.ID == "" {
+					call.ID = generatedIDPrefix + strconv.Itoa(len(result.ToolCalls))
+				}
+				if call.Arguments == "" || call.Arguments == "null" {
+					call.Arguments = "{}"
+				}
+				// Tool calls arrive whole, so the arguments are delivered as
+				// a single delta.
+				cb.EmitToolCallStart(llmstreamer.ToolCall{ID: call.ID, Name: call.Name})
+				cb.EmitToolCallDelta(call.ID, call.Arguments)
+				cb.EmitToolCall(call)
+				result.ToolCalls = append(result.ToolCalls, call)
+			}
+		}
+
+		if ev.Done {
+			result.RawStopReason = ev.DoneReason
+			result.StopReason = stopReason(ev.DoneReason)
+
+			usage := ev.Metrics.toUsage()
+			result.Usage = &usage
+			cb.EmitUsage(usage)
+			result.Metadata = ev.Metrics
+
+			finish()
+			return nil
+		}
+	}
+}
+
+// readerPool holds the readers of finished responses for reuse.
+var readerPool = sync.Pool{
+	New: func() any {
+		return bufio.NewReaderSize(nil, 16<<10)
+	},
+}
+
+// readLine returns the next non-empty line of an NDJSON stream. The last line
+// may end without a newline. The line points into r's buffer, or into *long
+// for lines longer than the buffer, and is only valid until the next call.
+func readLine(r *bufio.Reader, long *[]byte) ([]byte, error) {
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			*long = append((*long)[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = r.ReadSlice('\n')
+				*long = append(*long, line...)
+			}
+			line = *long
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// generatedIDPrefix marks the call IDs the client makes up for tool calls
+// that the server returned without one. They are not sent back.
+const generatedIDPrefix = "ollama_call_"
+
+func callID(id string) string {
+	if strings.HasPrefix(id, generatedIDPrefix) {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,277 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+func fixtureServer(t *testing.T, name string, onRequest func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	fixture, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if onRequest != nil {
+			onRequest(r)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write(fixture)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamChat(t *testing.T) {
+	var gotPath string
+	var body RequestBody
+	srv := fixtureServer(t, "chat.ndjson", func(r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+
+	s := New("llama3.2")
+	s.BaseURL = srv.URL
+	s.KeepAlive = "10m"
+
+	var content string
+	var usage llmstreamer.Usage
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{
+		{Role: llmstreamer.RoleDeveloper, Content: "Be brief."},
+		{Role: llmstreamer.RoleUser, Content: "Why is the sky blue?"},
+	}, &llmstreamer.StreamCallbacks{
+		OnContent:      func(c string) { content += c },
+		OnUsage:        func(u llmstreamer.Usage) { usage = u },
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}, llmstreamer.WithMaxTokens(64), llmstreamer.WithTemperature(0.2))
+
+	if gotPath != "/api/chat" {
+		t.Fatalf("unexpected path: %s", gotPath)
+	}
+	if body.Model != "llama3.2" || !body.Stream || body.KeepAlive != "10m" || body.Messages[0].Role != "system" {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if body.Options == nil || body.Options.NumPredict != 64 || *body.Options.Temperature != 0.2 {
+		t.Fatalf("unexpected options: %+v", body.Options)
+	}
+
+	if content != "The sky is blue." || result.Message != content {
+		t.Fatalf("content = %q, message = %q", content, result.Message)
+	}
+	if result.StopReason != llmstreamer.StopReasonEndTurn || result.RawStopReason != "stop" || result.Model != "llama3.2" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if usage != (llmstreamer.Usage{InputTokens: 26, OutputTokens: 4}) || result.Usage == nil || *result.Usage != usage {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	want := Metrics{
+		TotalDuration:      4883583458,
+		LoadDuration:       1334875,
+		PromptEvalCount:    26,
+		PromptEvalDuration: 342546 * time.Microsecond,
+		EvalCount:          4,
+		EvalDuration:       40 * time.Millisecond,
+	}
+	metrics, ok := result.Metadata.(Metrics)
+	if !ok || metrics != want {
+		t.Fatalf("metadata = %+v, want %+v", result.Metadata, want)
+	}
+	if tps := metrics.TokensPerSecond(); tps != 100 {
+		t.Fatalf("TokensPerSecond = %v", tps)
+	}
+}
+
+func TestStreamChat_ToolCall(t *testing.T) {
+	srv := fixtureServer(t, "tool_call.ndjson", nil)
+
+	s := New("qwen3")
+	s.BaseURL = srv.URL
+
+	var reasoning string
+	var started, done []llmstreamer.ToolCall
+	var result llmstreamer.FinishResult
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "Weather in Paris?"}}, &llmstreamer.StreamCallbacks{
+		OnReasoning:     func(r string) { reasoning += r },
+		OnToolCallStart: func(c llmstreamer.ToolCall) { started = append(started, c) },
+		OnToolCall:      func(c llmstreamer.ToolCall) { done = append(done, c) },
+		OnFinishResult:  func(r llmstreamer.FinishResult) { result = r },
+		OnError:         func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	want := llmstreamer.ToolCall{ID: "ollama_call_0", Name: "get_weather", Arguments: `{"city":"Paris"}`}
+	if len(started) != 1 || started[0].Name != "get_weather" || !reflect.DeepEqual(done, []llmstreamer.ToolCall{want}) {
+		t.Fatalf("started = %+v, done = %+v", started, done)
+	}
+	if reasoning != "The user wants the weather." || result.Reasoning != reasoning {
+		t.Fatalf("unexpected reasoning: %q", reasoning)
+	}
+	if result.StopReason != llmstreamer.StopReasonToolUse || !reflect.DeepEqual(result.ToolCalls, []llmstreamer.ToolCall{want}) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestNewRequestBody_Tools(t *testing.T) {
+	tool := llmstreamer.Tool{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}
+	call := llmstreamer.ToolCall{ID: "ollama_call_0", Name: "get_weather", Arguments: `{"city":"Paris"}`}
+
+	body, err := newRequestBody("qwen3", []llmstreamer.Message{
+		{Role: llmstreamer.RoleUser, Content: "Weather in Paris?"},
+		{Role: llmstreamer.RoleAssistant, ToolCalls: []llmstreamer.ToolCall{call}},
+		llmstreamer.ToolResult(call.ID, "18°C"),
+	}, llmstreamer.NewOptions(llmstreamer.WithTools(tool)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(body.Messages)
+	want := `[{"role":"user","content":"Weather in Paris?"},` +
+		`{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},` +
+		`{"role":"tool","content":"18°C","tool_name":"get_weather"}]`
+	if string(data) != want {
+		t.Fatalf("messages:\n got %s\nwant %s", data, want)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Name != "get_weather" {
+		t.Fatalf("unexpected tools: %+v", body.Tools)
+	}
+	if body.Options != nil {
+		t.Fatalf("expected no options, got %+v", body.Options)
+	}
+
+	body, err = newRequestBody("qwen3", nil, llmstreamer.NewOptions(llmstreamer.WithTools(tool), llmstreamer.WithToolChoice(llmstreamer.ToolChoiceNone)))
+	if err != nil || body.Tools != nil {
+		t.Fatalf("tool choice none: tools = %+v, err = %v", body.Tools, err)
+	}
+
+	_, err = newRequestBody("qwen3", nil, llmstreamer.NewOptions(llmstreamer.WithTools(tool), llmstreamer.WithForcedTool("get_weather")))
+	if !errors.Is(err, llmstreamer.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, got %v", err)
+	}
+}
+
+func TestStreamChat_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"model \"llama9\" not found, try pulling it first"}`)
+	}))
+	defer srv.Close()
+
+	s := New("llama9")
+	s.BaseURL = srv.URL
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{OnError: func(err error) { gotErr = err }})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.Provider != "ollama" || apiErr.StatusCode != http.StatusNotFound || !strings.Contains(apiErr.Message, "not found") {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+}
+
+func TestStreamChat_StreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hel"},"done":false}`+"\n")
+		io.WriteString(w, `{"error":"llama runner process has terminated: signal: killed"}`)
+	}))
+	defer srv.Close()
+
+	s := New("llama3.2")
+	s.BaseURL = srv.URL
+
+	var gotErr error
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { t.Fatalf("unexpected finish: %q", f) },
+		OnError:  func(err error) { gotErr = err },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) || apiErr.Message != "llama runner process has terminated: signal: killed" {
+		t.Fatalf("unexpected error: %v", gotErr)
+	}
+}
+
+func TestPull(t *testing.T) {
+	var body map[string]interface{}
+	srv := fixtureServer(t, "pull.ndjson", func(r *http.Request) {
+		if r.URL.Path != "/api/pull" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+
+	s := New("llama3.2")
+	s.BaseURL = srv.URL
+
+	var progress []PullProgress
+	if err := s.Pull(context.Background(), "", func(p PullProgress) { progress = append(progress, p) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if body["model"] != "llama3.2" || body["stream"] != true {
+		t.Fatalf("unexpected body: %v", body)
+	}
+	if len(progress) != 6 || progress[0].Status != "pulling manifest" || progress[5].Status != "success" {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	if p := progress[2]; p.Total != 2019377376 || p.Completed != 1009688688 || !strings.HasPrefix(p.Digest, "sha256:") {
+		t.Fatalf("unexpected download progress: %+v", p)
+	}
+}
+
+func TestPull_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"pulling manifest"}`+"\n")
+		io.WriteString(w, `{"error":"pull model manifest: file does not exist"}`+"\n")
+	}))
+	defer srv.Close()
+
+	s := New("")
+	s.BaseURL = srv.URL
+
+	err := s.Pull(context.Background(), "no-such-model", nil)
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "pull model manifest: file does not exist" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	// The long line does not fit in the reader's buffer.
+	long := strings.Repeat("x", 40<<10)
+	r := bufio.NewReaderSize(strings.NewReader("a\n\n  \n"+long+"\nlast"), 16)
+
+	var buf []byte
+	var got []string
+	for {
+		line, err := readLine(r, &buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(line))
+	}
+	if !reflect.DeepEqual(got, []string{"a", long, "last"}) {
+		t.Fatalf("got %d lines", len(got))
+	}
+}
@@ -0,0 +1,91 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PullProgress is one status update of a model download. Total and Completed
+// are byte counts of the layer named by Digest and are 0 for steps that do not
+// download anything, such as "pulling manifest" or "verifying sha256 digest".
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+type pullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+type pullEvent struct {
+	PullProgress
+	Error string `json:"error,omitempty"`
+}
+
+// Pull downloads model, or s.Model if model is empty, to the server and calls
+// onProgress, which may be nil, with every status update. Models that are
+// already present are only checked for updates. Pull returns once the server
+// reports success; a failed download is returned as an *llmstreamer.APIError.
+func (s *OllamaStreamer) Pull(ctx context.Context, model string, onProgress func(p PullProgress)) error {
+	if model == "" {
+		model = s.Model
+	}
+	if model == "" {
+		return errors.New("ollama: model is required")
+	}
+
+	client, req, err := s.prepareRequest(ctx, "/api/pull", pullRequest{Model: model, Stream: true})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
+		if err != nil {
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
+		}
+		return apiErr
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var long []byte
+	for {
+		line, err := readLine(reader, &long)
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("ollama: pull of %s ended without success", model)
+			}
+			return fmt.Errorf("read failed: %w", err)
+		}
+
+		var ev pullEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		if ev.Error != "" {
+			return newStreamError(ev.Error)
+		}
+
+		if onProgress != nil {
+			onProgress(ev.PullProgress)
+		}
+		if ev.Status == "success" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package ollama
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+type RequestBody struct {
+	Model     string          `json:"model"`
+	Messages  []Message       `json:"messages"`
+	Tools     []Tool          `json:"tools,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"`
+	Options   *ModelOptions   `json:"options,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the tool whose result a "tool" message carries.
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the arguments as a JSON object, not as a string like the
+// OpenAI API.
+type FunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type Tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+}
+
+type Function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ModelOptions are the runtime parameters Ollama takes in the options object.
+type ModelOptions struct {
+	NumPredict       int      `json:"num_predict,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+func newRequestBody(model string, messages []llmstreamer.Message, o llmstreamer.Options) (RequestBody, error) {
+	if err := validateOptions(o); err != nil {
+		return RequestBody{}, err
+	}
+
+	body := RequestBody{
+		Model:    model,
+		Messages: translateMessages(messages),
+		Stream:   true,
+	}
+
+	// Ollama has no tool choice; not offering the tools is the only way to
+	// keep the model from calling them.
+	if o.ToolChoice == nil || o.ToolChoice.Mode != llmstreamer.ToolChoiceNone {
+		body.Tools = translateTools(o.Tools)
+	}
+
+	opts := ModelOptions{
+		NumPredict:       o.MaxTokens,
+		Temperature:      o.Temperature,
+		TopP:             o.TopP,
+		TopK:             o.TopK,
+		Stop:             o.Stop,
+		Seed:             o.Seed,
+		PresencePenalty:  o.PresencePenalty,
+		FrequencyPenalty: o.FrequencyPenalty,
+	}
+	if opts.NumPredict != 0 || opts.Temperature != nil || opts.TopP != nil || opts.TopK != nil ||
+		len(opts.Stop) > 0 || opts.Seed != nil || opts.PresencePenalty != nil || opts.FrequencyPenalty != nil {
+		body.Options = &opts
+	}
+
+	if rf := o.ResponseFormat; rf != nil {
+		body.Format = rf.Schema
+	}
+
+	return body, nil
+}
+
+// translateMessages converts messages to the chat format. Developer messages
+// are sent as system messages, and tool results carry the name of the tool
+// that produced them because Ollama matches results to calls by name.
+func translateMessages(messages []llmstreamer.Message) []Message {
+	out := make([]Message, 0, len(messages))
+	names := make(map[string]string)
+
+	for _, m := range messages {
+		msg := Message{Role: string(m.Role), Content: m.Content}
+
+		switch m.Role {
+		case llmstreamer.RoleDeveloper:
+			msg.Role = string(llmstreamer.RoleSystem)
+		case llmstreamer.RoleTool:
+			msg.ToolName = names[m.ToolCallID]
+		}
+
+		for _, tc := range m.ToolCalls {
+			names[tc.ID] = tc.Name
+			args := json.RawMessage(tc.Arguments)
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       callID(tc.ID),
+				Function: FunctionCall{Name: tc.Name, Arguments: args},
+			})
+		}
+
+		out = append(out, msg)
+	}
+	return out
+}
+
+func translateTools(tools []llmstreamer.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]Tool, len(tools))
+	for i, t := range tools {
+		out[i] = Tool{
+			Type:     "function",
+			Function: Function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		}
+	}
+	return out
+}
+
+func validateOptions(o llmstreamer.Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if c := o.ToolChoice; c != nil && (c.Mode == llmstreamer.ToolChoiceRequired || c.Mode == llmstreamer.ToolChoiceTool) {
+		return llmstreamer.Unsupported("tool_choice " + string(c.Mode))
+	}
+	return nil
+}
+
+// StreamEvent is one line of the NDJSON response. The final line has Done set
+// and carries the done reason and the Metrics.
+type StreamEvent struct {
+	Model      string   `json:"model"`
+	CreatedAt  string   `json:"created_at"`
+	Message    *Message `json:"message,omitempty"`
+	Done       bool     `json:"done"`
+	DoneReason string   `json:"done_reason,omitempty"`
+	// Error is set when the server fails after the stream has started.
+	Error string `json:"error,omitempty"`
+
+	Metrics
+}
+
+// Metrics are the statistics Ollama reports at the end of a response. The
+// durations are sent in nanoseconds.
+type Metrics struct {
+	// TotalDuration is the time spent generating the response, including
+	// LoadDuration.
+	TotalDuration time.Duration `json:"total_duration,omitempty"`
+	// LoadDuration is the time spent loading the model into memory. It is
+	// close to zero when the model was already loaded.
+	LoadDuration time.Duration `json:"load_duration,omitempty"`
+
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int           `json:"eval_count,omitempty"`
+	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+}
+
+// TokensPerSecond is the generation speed, excluding prompt processing.
+func (m Metrics) TokensPerSecond() float64 {
+	if m.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(m.EvalCount) / m.EvalDuration.Seconds()
+}
+
+// toUsage converts the token counts. Local models have no price, so Cost is
+// always 0.
+func (m Metrics) toUsage() llmstreamer.Usage {
+	return llmstreamer.Usage{
+		InputTokens:  m.PromptEvalCount,
+		OutputTokens: m.EvalCount,
+	}
+}
+
+func stopReason(reason string) llmstreamer.StopReason {
+	switch reason {
+	case "stop":
+		return llmstreamer.StopReasonEndTurn
+	case "length":
+		return llmstreamer.StopReasonMaxTokens
+	}
+	return llmstreamer.StopReasonOther
+}
@@ -0,0 +1,85 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// longStream builds a response of n single-token content chunks, the shape of
+// almost every chunk in a long answer.
+func longStream(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		token := " token"
+		if i%20 == 19 {
+			token = `.\n`
+		}
+		fmt.Fprintf(&b, `data: {"id":"chatcmpl-B9MBs8CjcvOU2jLn4n570S5qMJKcT","object":"chat.completion.chunk","created":1741569952,"model":"gpt-4o-2024-08-06","service_tier":"default","system_fingerprint":"fp_eb9dce56a8","choices":[{"index":0,"delta":{"content":"%s"},"logprobs":null,"finish_reason":null}]}`+"\n\n", token)
+	}
+	b.WriteString(`data: {"id":"chatcmpl-B9MBs8CjcvOU2jLn4n570S5qMJKcT","object":"chat.completion.chunk","created":1741569952,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{},"logprobs":null,"finish_reason":"stop"}]}` + "\n\n")
+	b.WriteString("data: [DONE]\n\n")
+	return b.Bytes()
+}
+
+func BenchmarkProcessStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	cb := &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { n += len(c) },
+		OnError:   func(err error) { b.Fatal(err) },
+	}
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(stream))}
+		if err := processStream(resp, cb); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLegacyStream_100kTokens decodes the same response the way streams
+// were read before the scanner: a bufio.Scanner over the lines and
+// encoding/json for every chunk. It is the baseline for the benchmark above.
+func BenchmarkLegacyStream_100kTokens(b *testing.B) {
+	stream := longStream(100_000)
+
+	var n int
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var message strings.Builder
+		sc := bufio.NewScanner(bytes.NewReader(stream))
+		for sc.Scan() {
+			data, ok := bytes.CutPrefix(sc.Bytes(), []byte("data: "))
+			if !ok || bytes.Equal(data, []byte("[DONE]")) {
+				continue
+			}
+			var ev StreamEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				b.Fatal(err)
+			}
+			if len(ev.Choices) > 0 {
+				message.WriteString(ev.Choices[0].Delta.Content)
+				n += len(ev.Choices[0].Delta.Content)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/alparslanyilmaaz/llmstreamer/internal/jsonscan"
+)
+
+// chunkDecoder decodes stream chunks. Almost every chunk of a response is a
+// single choice carrying a bit of content or reasoning, so that shape is read
+// with a scanner that reuses the previous chunk's strings; anything else goes
+// through encoding/json. The decoded event shares memory with the decoder and
+// is only valid until the next call.
+type chunkDecoder struct {
+	scan   jsonscan.Scanner
+	ev     StreamEvent
+	choice [1]Choice
+}
+
+func (d *chunkDecoder) decode(data []byte) (StreamEvent, error) {
+	if d.decodeDelta(data) {
+		return d.ev, nil
+	}
+	var ev StreamEvent
+	err := json.Unmarshal(data, &ev)
+	return ev, err
+}
+
+// decodeDelta decodes data into d.ev if it is a chunk with at most one choice
+// and nothing but text in its delta. It reports false, leaving the caller to
+// use encoding/json, for any other chunk.
+func (d *chunkDecoder) decodeDelta(data []byte) bool {
+	s := &d.scan
+	s.Reset(data)
+	prev := d.ev
+	ev := StreamEvent{}
+	choice := &d.choice[0]
+
+	ok := s.Object(func(key []byte) bool {
+		switch string(key) {
+		case "id":
+			return s.ReadString(&ev.ID, prev.ID)
+		case "object":
+			return s.ReadString(&ev.Object, prev.Object)
+		case "created":
+			if s.Null() {
+				return true
+			}
+			var ok bool
+			ev.Created, ok = s.Int()
+			return ok
+		case "model":
+			return s.ReadString(&ev.Model, prev.Model)
+		case "service_tier":
+			return s.ReadString(&ev.ServiceTier, prev.ServiceTier)
+		case "system_fingerprint":
+			return s.ReadString(&ev.SystemFingerprint, prev.SystemFingerprint)
+		case "obfuscation":
+			return s.ReadString(&ev.Obfuscation, "")
+		case "usage", "error":
+			return s.Null()
+		case "choices":
+			if s.Null() {
+				ev.Choices = nil
+				return true
+			}
+			ev.Choices = d.choice[:0]
+			*choice = Choice{}
+			return s.Array(func() bool {
+				if len(ev.Choices) == 1 {
+					return false
+				}
+				ev.Choices = d.choice[:1]
+				return d.decodeChoice(choice)
+			})
+		}
+		return false
+	})
+	if !ok || !s.End() {
+		return false
+	}
+	d.ev = ev
+	return true
+}
+
+func (d *chunkDecoder) decodeChoice(c *Choice) bool {
+	s := &d.scan
+	return s.Object(func(key []byte) bool {
+		switch string(key) {
+		case "index":
+			if s.Null() {
+				return true
+			}
+			n, ok := s.Int()
+			c.Index = int(n)
+			return ok
+		case "logprobs":
+			return s.Null()
+		case "finish_reason":
+			if s.Null() {
+				c.FinishReason = nil
+				return true
+			}
+			b, ok := s.String()
+			reason := string(b)
+			c.FinishReason = &reason
+			return ok
+		case "delta":
+			if s.Null() {
+				return true
+			}
+			return s.Object(func(key []byte) bool {
+				switch string(key) {
+				case "role":
+					return s.ReadString(&c.Delta.Role, "assistant")
+				case "content":
+					return s.ReadString(&c.Delta.Content, "")
+				case "reasoning_content":
+					return s.ReadString(&c.Delta.ReasoningContent, "")
+				case "reasoning":
+					return s.ReadString(&c.Delta.Reasoning, "")
+				}
+				return false
+			})
+		}
+		return false
+	})
+}
@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChunkDecoder(t *testing.T) {
+	chunks := []struct {
+		data string
+		fast bool
+	}{
+		{`{"id":"c1","object":"chat.completion.chunk","created":1741569952,"model":"gpt-4o","service_tier":"default","system_fingerprint":"fp_1","choices":[{"index":0,"delta":{"role":"assistant","content":"","refusal":null},"logprobs":null,"finish_reason":null}]}`, false},
+		{`{"id":"c1","object":"chat.completion.chunk","created":1741569952,"model":"gpt-4o","system_fingerprint":"fp_1","choices":[{"index":0,"delta":{"role":"assistant","content":""},"logprobs":null,"finish_reason":null}]}`, true},
+		{`{"id":"c1","object":"chat.completion.chunk","created":1741569952,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hi \"there\"\né😀"},"logprobs":null,"finish_reason":null}],"obfuscation":"x1"}`, true},
+		{` {"id":"c1","choices":[{"index":0,"delta":{"reasoning_content":"hmm","reasoning":null},"finish_reason":null}],"usage":null} `, true},
+		{`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`, true},
+		{`{"id":"c1","choices":[]}`, true},
+		{`{"id":"c1","choices":null}`, true},
+		// Everything else is left to encoding/json.
+		{`{"id":"c1","choices":[{"index":0,"delta":{"content":"a"}},{"index":1,"delta":{"content":"b"}}]}`, false},
+		{`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`, false},
+		{`{"id":"c1","choices":[],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`, false},
+		{`{"id":"c1","choices":[{"index":0,"delta":{"content":"a"},"content_filter_results":{}}]}`, false},
+		{`{"error":{"message":"boom"}}`, false},
+		{`{"id":"c1","choices":[{"index":0,"delta":{"content":"a"}}]`, false},
+		{`{"id":"c1"} trailing`, false},
+		{`{"id":"c1","created":1.5}`, false},
+	}
+
+	var d chunkDecoder
+	for _, c := range chunks {
+		if ok := d.decodeDelta([]byte(c.data)); ok != c.fast {
+			t.Errorf("decodeDelta(%s) = %v, want %v", c.data, ok, c.fast)
+		}
+
+		got, gotErr := d.decode([]byte(c.data))
+		var want StreamEvent
+		wantErr := json.Unmarshal([]byte(c.data), &want)
+		if (gotErr != nil) != (wantErr != nil) || !reflect.DeepEqual(got, want) {
+			t.Errorf("decode(%s):\n got %+v, %v\nwant %+v, %v", c.data, got, gotErr, want, wantErr)
+		}
+	}
+}
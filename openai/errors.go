@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+type ErrorBody struct {
+	Error *ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Param   interface{} `json:"param"`
+	Code    interface{} `json:"code"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "openai",
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("x-request-id"),
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+	if e.RetryAfter == 0 && resp.StatusCode == http.StatusTooManyRequests {
+		e.RetryAfter = llmstreamer.ParseRateLimitReset(resp.Header)
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Error == nil {
+		e.Body = string(body)
+		return e
+	}
+	eb.Error.apply(e)
+	return e
+}
+
+// newStreamError converts an error object received in a data line after a 200
+// response.
+func newStreamError(resp *http.Response, d *ErrorDetail) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:  "openai",
+		RequestID: resp.Header.Get("x-request-id"),
+	}
+	d.apply(e)
+	return e
+}
+
+func (d *ErrorDetail) apply(e *llmstreamer.APIError) {
+	e.Type = d.Type
+	e.Message = d.Message
+	// The code is a string on api.openai.com but a number on some compatible
+	// servers.
+	if d.Code != nil {
+		e.Code = fmt.Sprint(d.Code)
+	}
+}
@@ -1,5 +1,9 @@
 package openai
 
+import "strings"
+
+// Model names an OpenAI model. Any string is accepted, so models served by
+// OpenAI-compatible servers can be used as Model("llama3.1:8b").
 type Model string
 
 const (
@@ -9,3 +13,43 @@ const (
 
 	ModelGPT35Turbo Model = "gpt-3.5-turbo"
 )
+
+// legacyFamilies are the prefixes of the legacy chat models, including their
+// dated snapshots such as "gpt-4o-2024-08-06" and "gpt-3.5-turbo-0125".
+var legacyFamilies = []string{"gpt-4o", "gpt-4-", "gpt-3.5-"}
+
+// SupportsDeveloperRole reports whether the model accepts "developer" messages.
+// The legacy chat models (GPT-4o, GPT-4 Turbo, GPT-4 and GPT-3.5 Turbo with
+// their snapshots) only understand "system", as do the models served by
+// OpenAI-compatible servers. Other gpt- and o-series models are assumed to be
+// newer ones and receive developer messages unchanged.
+func (m Model) SupportsDeveloperRole() bool {
+	name := string(m)
+	if name == "gpt-4" {
+		return false
+	}
+	for _, prefix := range legacyFamilies {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	if strings.HasPrefix(name, "gpt-") || strings.HasPrefix(name, "chatgpt-") {
+		return true
+	}
+	return isOSeries(name)
+}
+
+// UsesMaxCompletionTokens reports whether the model takes its output limit as
+// max_completion_tokens. The o-series and GPT-5 reasoning models reject
+// max_tokens; other models, including those served by OpenAI-compatible
+// servers, receive max_tokens.
+func (m Model) UsesMaxCompletionTokens() bool {
+	name := string(m)
+	return strings.HasPrefix(name, "gpt-5") || isOSeries(name)
+}
+
+// isOSeries reports whether name is an o-series model such as "o1" or
+// "o4-mini".
+func isOSeries(name string) bool {
+	return len(name) > 1 && name[0] == 'o' && name[1] >= '0' && name[1] <= '9'
+}
@@ -1,7 +1,6 @@
 package openai
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,13 +8,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/sse"
 )
 
 type OpenAIStreamer struct {
 	ApiKey string
 	Model  Model
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces DefaultBaseURL, for proxies and local stand-ins.
+	BaseURL string
+	// Header is added to every request, for example OpenAI-Organization or
+	// OpenAI-Project. It overrides the headers set by the streamer.
+	Header http.Header
 }
 
 func New(apiKey string, model Model) *OpenAIStreamer {
@@ -25,18 +43,49 @@ func New(apiKey string, model Model) *OpenAIStreamer {
 	}
 }
 
-const url = "https://api.openai.com/v1/chat/completions"
+// NewCompatible returns a streamer for a server that implements the OpenAI
+// chat completions API, such as Ollama, vLLM, LM Studio or llama.cpp.
+// baseURL is the URL up to and including /v1, e.g.
+// "http://localhost:11434/v1". Set ApiKey if the server requires one.
+func NewCompatible(baseURL string, model string) *OpenAIStreamer {
+	return &OpenAIStreamer{
+		Model:   Model(model),
+		BaseURL: baseURL,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*OpenAIStreamer)(nil)
+	_ llmstreamer.Warmer   = (*OpenAIStreamer)(nil)
+)
+
+func init() {
+	llmstreamer.Register("openai", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		s := New(cfg.APIKey, Model(cfg.Model))
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		return s, nil
+	})
+}
+
+const DefaultBaseURL = "https://api.openai.com/v1"
 
 func (s *OpenAIStreamer) StreamChat(
 	ctx context.Context,
 	messages []llmstreamer.Message,
 	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
 ) {
-	if s.ApiKey == "" {
-		if cb != nil && cb.OnError != nil {
-			cb.OnError(errors.New("invalid apiKey"))
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
 
-		}
+	// OpenAI-compatible servers often run without authentication, so the
+	// key is only required for the default endpoint.
+	if s.ApiKey == "" && s.BaseURL == "" {
+		cb.EmitError(errors.New("invalid apiKey"))
 		return
 	}
 
@@ -45,22 +94,43 @@ func (s *OpenAIStreamer) StreamChat(
 		model = ModelGPT4o
 	}
 
-	payload := RequestBody{
-		Model:     model,
-		Messages:  messages,
-		MaxTokens: 1024,
-		Stream:    true,
+	payload, err := NewRequestBody(model, messages, llmstreamer.NewOptions(opts...))
+	if err != nil {
+		cb.EmitError(err)
+		return
 	}
 
-	if err := streamOpenAI(ctx, payload, s.ApiKey, cb); err != nil {
-		if cb != nil && cb.OnError != nil {
-			cb.OnError(err)
-		}
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, payload, cb)
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
 	}
 }
 
-func streamOpenAI(ctx context.Context, payload RequestBody, apiKey string, cb *llmstreamer.StreamCallbacks) error {
-	client, req, err := prepareRequest(ctx, payload, apiKey)
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *OpenAIStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *OpenAIStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint())
+}
+
+func (s *OpenAIStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *OpenAIStreamer) stream(ctx context.Context, payload RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, payload)
 
 	if err != nil {
 		return err
@@ -76,87 +146,189 @@ func streamOpenAI(ctx context.Context, payload RequestBody, apiKey string, cb *l
 	}
 
 	defer resp.Body.Close()
-	processStream(resp, cb)
-	return nil
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
 }
 
-func prepareRequest(ctx context.Context, payload RequestBody, apiKey string) (*http.Client, *http.Request, error) {
+func (s *OpenAIStreamer) prepareRequest(ctx context.Context, payload RequestBody) (*http.Client, *http.Request, error) {
 	data, err := json.Marshal(payload)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader(data))
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if s.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.ApiKey)
+	}
 	req.Header.Set("Content-Type", "application/json")
+	llmstreamer.SetHeaders(req.Header, s.Header)
 
-	client := &http.Client{
-		Timeout: 0,
-	}
+	client := s.client()
 
 	return client, req, nil
 }
 
-func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) {
+func (s *OpenAIStreamer) endpoint() string {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/chat/completions"
+}
+
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
+	return ProcessStream(resp, cb, nil)
+}
+
+// ProcessStream delivers a chat completions stream to cb. Errors that end the
+// stream are returned rather than emitted so the caller can decide whether to
+// retry. If onEvent is not nil it is called with every decoded chunk before
+// the chunk is delivered, which lets providers that extend the OpenAI format
+// read their own fields.
+func ProcessStream(resp *http.Response, cb *llmstreamer.StreamCallbacks, onEvent func(ev StreamEvent)) error {
 	if resp.StatusCode != http.StatusOK {
 		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
 		if err != nil {
-			cb.OnError(fmt.Errorf("non-200: %d, read body failed: %w", resp.StatusCode, err))
-			return
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
 		}
-		cb.OnError(fmt.Errorf("non-200: %d, body: %s", resp.StatusCode, string(b)))
-		return
+		return apiErr
 	}
 
-	reader := bufio.NewReader(resp.Body)
-	var finalMessage string
+	dec := sse.NewDecoder(resp.Body)
+	// Some OpenAI-compatible servers close the stream without [DONE] or the
+	// blank line after the last event.
+	dec.DispatchAtEOF = true
+	defer dec.Release()
+
+	var chunks chunkDecoder
+	var finalMessage, reasoning strings.Builder
+	var result llmstreamer.FinishResult
+	tools := newToolCalls()
+
+	finish := func() {
+		result.ToolCalls = append(result.ToolCalls, tools.flush(cb)...)
+		result.Message = finalMessage.String()
+		result.Reasoning = reasoning.String()
+		cb.EmitFinishResult(result)
+	}
 
 	for {
-		line, err := reader.ReadBytes('\n')
+		event, err := dec.Next()
 		if err != nil {
 			if err == io.EOF {
-				cb.OnFinish(finalMessage)
-				return
+				finish()
+				return nil
 			}
-			cb.OnError(fmt.Errorf("read failed: %w", err))
-			return
+			return fmt.Errorf("read failed: %w", err)
 		}
 
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
+		if bytes.Equal(event.Data, []byte("[DONE]")) {
+			finish()
+			return nil
+		}
+
+		ev, err := chunks.decode(event.Data)
+		if err != nil {
+			cb.EmitError(fmt.Errorf("failed to parse JSON: %w", err))
 			continue
 		}
 
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			data := line[len("data: "):]
+		if ev.Error != nil {
+			return newStreamError(resp, ev.Error)
+		}
+		if onEvent != nil {
+			// The decoder reuses the choices of the previous chunk, and
+			// onEvent may keep them.
+			kept := ev
+			kept.Choices = slices.Clone(ev.Choices)
+			onEvent(kept)
+		}
 
-			if bytes.Equal(data, []byte("[DONE]")) {
-				cb.OnFinish(finalMessage)
-				return
+		updateResult(&result, ev)
+
+		if ev.Usage != nil {
+			usage := ev.Usage.toUsage(ev.Model)
+			result.Usage = &usage
+			cb.EmitUsage(usage)
+		}
+
+		if len(ev.Choices) > 0 {
+			choice := ev.Choices[0]
+
+			if r := choice.Delta.reasoning(); r != "" {
+				reasoning.WriteString(r)
+				cb.EmitReasoning(r)
 			}
 
-			var ev StreamEvent
-			if err := json.Unmarshal(data, &ev); err != nil {
-				cb.OnError(fmt.Errorf("failed to parse JSON: %w", err))
-				continue
+			content := choice.Delta.Content
+			if content != "" {
+				finalMessage.WriteString(content)
+				cb.EmitContent(content)
 			}
 
-			if len(ev.Choices) > 0 {
-				content := ev.Choices[0].Delta.Content
-				if content != "" {
-					finalMessage += content
-					if cb != nil && cb.OnContent != nil {
-						cb.OnContent(content)
-					}
-				}
+			for _, d := range choice.Delta.ToolCalls {
+				tools.add(d, cb)
 			}
 
+			// Some compatible servers send an empty finish_reason
+			// instead of null while the response is still streaming.
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				result.ToolCalls = append(result.ToolCalls, tools.flush(cb)...)
+				result.RawStopReason = *choice.FinishReason
+				result.StopReason = stopReason(*choice.FinishReason)
+			}
 		}
 	}
 }
+
+// toolCalls assembles streamed tool call fragments. OpenAI sends the call ID
+// and name in the first fragment for an index and only the index afterwards.
+type toolCalls struct {
+	byIndex map[int]*pendingCall
+	order   []int
+}
+
+type pendingCall struct {
+	call llmstreamer.ToolCall
+	args strings.Builder
+}
+
+func newToolCalls() *toolCalls {
+	return &toolCalls{byIndex: make(map[int]*pendingCall)}
+}
+
+func (t *toolCalls) add(d ToolCallDelta, cb *llmstreamer.StreamCallbacks) {
+	p, ok := t.byIndex[d.Index]
+	if !ok {
+		p = &pendingCall{call: llmstreamer.ToolCall{ID: d.ID, Name: d.Function.Name}}
+		t.byIndex[d.Index] = p
+		t.order = append(t.order, d.Index)
+		cb.EmitToolCallStart(p.call)
+	}
+
+	if d.Function.Arguments != "" {
+		p.args.WriteString(d.Function.Arguments)
+		cb.EmitToolCallDelta(p.call.ID, d.Function.Arguments)
+	}
+}
+
+func (t *toolCalls) flush(cb *llmstreamer.StreamCallbacks) []llmstreamer.ToolCall {
+	var calls []llmstreamer.ToolCall
+	for _, i := range t.order {
+		p := t.byIndex[i]
+		call := p.call
+		call.Arguments = p.args.String()
+		calls = append(calls, call)
+		cb.EmitToolCall(call)
+	}
+	t.byIndex = make(map[int]*pendingCall)
+	t.order = nil
+	return calls
+}
@@ -5,9 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/alparslanyilmaaz/llmstreamer"
 )
@@ -56,9 +63,9 @@ func TestStreamChat_DefaultModel(t *testing.T) {
 	defer func() { http.DefaultTransport = orig }()
 
 	body := "" +
-		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: [DONE]` + "\n"
+		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
 
 	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 		b, _ := io.ReadAll(req.Body)
@@ -111,7 +118,7 @@ func TestStreamChat_TransportError(t *testing.T) {
 func TestStreamAnthropic_Success(t *testing.T) {
 	payload := RequestBody{
 		Model:     ModelGPT35Turbo,
-		Messages:  []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
+		Messages:  []Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
 		MaxTokens: 5,
 		Stream:    true,
 	}
@@ -122,9 +129,9 @@ func TestStreamAnthropic_Success(t *testing.T) {
 	defer func() { http.DefaultTransport = origTransport }()
 
 	body := "" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n" +
-		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" there\"}}\n" +
-		"data: {\"type\":\"message_stop\"}\n"
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\" there\"}}\n\n" +
+		"data: {\"type\":\"message_stop\"}\n\n"
 
 	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
 		if req.Method != http.MethodPost {
@@ -144,9 +151,9 @@ func TestStreamAnthropic_Success(t *testing.T) {
 		OnError:   func(err error) { t.Fatalf("unexpected OnError: %v", err) },
 	}
 
-	err := streamOpenAI(context.Background(), payload, apiKey, cb)
+	err := New(apiKey, "").stream(context.Background(), payload, cb)
 	if err != nil {
-		t.Fatalf("streamOpenAI returned error: %v", err)
+		t.Fatalf("stream returned error: %v", err)
 	}
 	if !called {
 		t.Fatalf("expected at least one callback to be called")
@@ -156,13 +163,13 @@ func TestStreamAnthropic_Success(t *testing.T) {
 func TestPrepareRequest_Success(t *testing.T) {
 	payload := RequestBody{
 		Model:     ModelGPT35Turbo,
-		Messages:  []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
+		Messages:  []Message{{Role: llmstreamer.RoleUser, Content: "hello"}},
 		MaxTokens: 5,
 		Stream:    true,
 	}
 
 	apiKey := "test-key"
-	client, req, err := prepareRequest(context.Background(), payload, apiKey)
+	client, req, err := New(apiKey, "").prepareRequest(context.Background(), payload)
 	if err != nil {
 		t.Fatalf("prepareRequest returned error: %v", err)
 	}
@@ -176,8 +183,8 @@ func TestPrepareRequest_Success(t *testing.T) {
 	if req.Method != http.MethodPost {
 		t.Fatalf("expected POST method, got %s", req.Method)
 	}
-	if req.URL == nil || req.URL.String() != url {
-		t.Fatalf("expected URL %s, got %v", url, req.URL)
+	if req.URL == nil || req.URL.String() != DefaultBaseURL+"/chat/completions" {
+		t.Fatalf("expected URL %s/chat/completions, got %v", DefaultBaseURL, req.URL)
 	}
 
 	if got := req.Header.Get("Authorization"); got != "Bearer "+apiKey {
@@ -213,9 +220,9 @@ func TestPrepareRequest_Success(t *testing.T) {
 
 func TestProcessStream_DeltaFinish(t *testing.T) {
 	body := "" +
-		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: [DONE]` + "\n"
+		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -256,14 +263,7 @@ func TestProcessStream_Non200(t *testing.T) {
 		Body:       io.NopCloser(strings.NewReader("bad request")),
 	}
 
-	var gotErr error
-	cb := &llmstreamer.StreamCallbacks{
-		OnError: func(err error) {
-			gotErr = err
-		},
-	}
-
-	processStream(resp, cb)
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
 
 	if gotErr == nil {
 		t.Fatalf("expected an error for non-200 response")
@@ -279,15 +279,10 @@ func TestProcessStream_Non200ReadError(t *testing.T) {
 		Body:       errReadCloser{},
 	}
 
-	var gotErr error
-	cb := &llmstreamer.StreamCallbacks{
-		OnError: func(err error) { gotErr = err },
-	}
-
-	processStream(resp, cb)
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
 
 	if gotErr == nil {
-		t.Fatalf("expected OnError to be called when Read fails")
+		t.Fatalf("expected an error when Read fails")
 	}
 	if !strings.Contains(gotErr.Error(), "read body failed") {
 		t.Fatalf("expected error message to mention read body failure, got: %v", gotErr)
@@ -300,16 +295,14 @@ func TestProcessStream_ReadFailedInLoop(t *testing.T) {
 		Body:       errReadCloser{},
 	}
 
-	var gotErr error
 	cb := &llmstreamer.StreamCallbacks{
-		OnError:  func(err error) { gotErr = err },
 		OnFinish: func(s string) { t.Fatalf("unexpected finish: %q", s) },
 	}
 
-	processStream(resp, cb)
+	gotErr := processStream(resp, cb)
 
 	if gotErr == nil {
-		t.Fatalf("expected OnError when reader returns error during streaming")
+		t.Fatalf("expected an error when reader returns error during streaming")
 	}
 	if !strings.Contains(gotErr.Error(), "read failed") {
 		t.Fatalf("expected error message to contain 'read failed', got: %v", gotErr)
@@ -317,10 +310,11 @@ func TestProcessStream_ReadFailedInLoop(t *testing.T) {
 }
 
 func TestProcessStream_EOFTriggersFinish(t *testing.T) {
+	// The server closes the stream without [DONE] or a blank line after the
+	// last event.
 	body := "" +
-		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: [DONE]` + "\n"
+		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -343,9 +337,9 @@ func TestProcessStream_EOFTriggersFinish(t *testing.T) {
 
 func TestProcessStream_InvalidJSONThenValid(t *testing.T) {
 	body := "" +
-		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"g` + "\n" +
-		`data: [DONE]` + "\n"
+		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"g` + "\n\n" +
+		`data: [DONE]` + "\n\n"
 
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
@@ -388,9 +382,9 @@ func TestProcessStream_InvalidJSONThenValid(t *testing.T) {
 
 func TestProcessStream_IgnoreEmptyLines(t *testing.T) {
 	body := "" +
-		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n" +
-		`data: [DONE]` + "\n"
+		`data: {"id":"evt1","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"evt2","object":"chat.completion.chunk","created":1690,"model":"gpt","choices":[{"index":0,"delta":{"content":" world"},"logprobs":null,"finish_reason":null}]}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
 	resp := &http.Response{
 		StatusCode: http.StatusOK,
 		Body:       io.NopCloser(strings.NewReader(body)),
@@ -417,3 +411,816 @@ func TestProcessStream_IgnoreEmptyLines(t *testing.T) {
 		t.Fatalf("unexpected final message: %q", final)
 	}
 }
+
+func TestRegisteredProvider(t *testing.T) {
+	s, err := llmstreamer.Open("openai", llmstreamer.Config{APIKey: "k", Model: string(ModelGPT4oMini)})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	o, ok := s.(*OpenAIStreamer)
+	if !ok {
+		t.Fatalf("expected *OpenAIStreamer, got %T", s)
+	}
+	if o.ApiKey != "k" || o.Model != ModelGPT4oMini {
+		t.Fatalf("unexpected streamer config: %+v", o)
+	}
+}
+
+func TestNewRequestBody_Options(t *testing.T) {
+	o := llmstreamer.NewOptions(
+		llmstreamer.WithMaxTokens(64),
+		llmstreamer.WithTemperature(1.2),
+		llmstreamer.WithTopP(0.8),
+		llmstreamer.WithStop("END"),
+		llmstreamer.WithSeed(42),
+		llmstreamer.WithPresencePenalty(0.5),
+		llmstreamer.WithFrequencyPenalty(-0.5),
+	)
+
+	p, err := NewRequestBody(ModelGPT4o, nil, o)
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if got["max_tokens"] != float64(64) {
+		t.Fatalf("expected max_tokens 64, got %v", got["max_tokens"])
+	}
+	if got["temperature"] != 1.2 || got["top_p"] != 0.8 || got["seed"] != float64(42) {
+		t.Fatalf("sampling params not mapped: %v", got)
+	}
+	if got["presence_penalty"] != 0.5 || got["frequency_penalty"] != -0.5 {
+		t.Fatalf("penalties not mapped: %v", got)
+	}
+	if stops, ok := got["stop"].([]interface{}); !ok || len(stops) != 1 || stops[0] != "END" {
+		t.Fatalf("expected stop [END], got %v", got["stop"])
+	}
+}
+
+func TestNewRequestBody_NoDefaultMaxTokens(t *testing.T) {
+	p, err := NewRequestBody(ModelGPT4o, nil, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	if strings.Contains(string(b), "max_tokens") || strings.Contains(string(b), "max_completion_tokens") {
+		t.Fatalf("unset max tokens should be omitted: %s", b)
+	}
+	if strings.Contains(string(b), "temperature") || strings.Contains(string(b), "seed") {
+		t.Fatalf("unset options should be omitted: %s", b)
+	}
+}
+
+func TestNewRequestBody_MaxCompletionTokens(t *testing.T) {
+	for model, field := range map[Model]string{
+		"o3-mini":        "max_completion_tokens",
+		"o1":             "max_completion_tokens",
+		"gpt-5-mini":     "max_completion_tokens",
+		ModelGPT4o:       "max_tokens",
+		"gpt-4.1":        "max_tokens",
+		"llama3.1:8b":    "max_tokens",
+		"openhermes-2.5": "max_tokens",
+	} {
+		p, err := NewRequestBody(model, nil, llmstreamer.Options{MaxTokens: 256})
+		if err != nil {
+			t.Fatalf("NewRequestBody returned error: %v", err)
+		}
+
+		var got map[string]interface{}
+		b, _ := json.Marshal(p)
+		json.Unmarshal(b, &got)
+		if got[field] != float64(256) {
+			t.Errorf("%s: expected %s 256 in %s", model, field, b)
+		}
+		other := "max_tokens"
+		if field == other {
+			other = "max_completion_tokens"
+		}
+		if _, ok := got[other]; ok {
+			t.Errorf("%s: unexpected %s in %s", model, other, b)
+		}
+	}
+}
+
+func TestNewRequestBody_InvalidOptions(t *testing.T) {
+	if _, err := NewRequestBody(ModelGPT4o, nil, llmstreamer.NewOptions(llmstreamer.WithTopK(10))); !errors.Is(err, llmstreamer.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption for top_k, got %v", err)
+	}
+
+	invalid := []llmstreamer.Option{
+		llmstreamer.WithTemperature(2.5),
+		llmstreamer.WithPresencePenalty(3),
+		llmstreamer.WithFrequencyPenalty(-3),
+		llmstreamer.WithStop("a", "b", "c", "d", "e"),
+	}
+	for _, opt := range invalid {
+		if _, err := NewRequestBody(ModelGPT4o, nil, llmstreamer.NewOptions(opt)); err == nil {
+			t.Fatalf("expected validation error")
+		}
+	}
+}
+
+func TestStreamChat_InvalidOptionCallsOnError(t *testing.T) {
+	s := New("test-key", "")
+
+	var gotErr error
+	cb := &llmstreamer.StreamCallbacks{
+		OnError: func(err error) { gotErr = err },
+	}
+
+	s.StreamChat(context.Background(), nil, cb, llmstreamer.WithTopK(3))
+
+	if !errors.Is(gotErr, llmstreamer.ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, got %v", gotErr)
+	}
+}
+
+func TestNewRequestBody_SystemAndDeveloperMessages(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleSystem, Content: "You are terse."},
+		{Role: llmstreamer.RoleDeveloper, Content: "Answer in English."},
+		{Role: llmstreamer.RoleUser, Content: "hi"},
+	}
+
+	legacy, err := NewRequestBody(ModelGPT4o, messages, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+	if len(legacy.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(legacy.Messages))
+	}
+	if legacy.Messages[0].Role != llmstreamer.RoleSystem || legacy.Messages[1].Role != llmstreamer.RoleSystem {
+		t.Fatalf("expected developer message to become system for %s: %+v", ModelGPT4o, legacy.Messages)
+	}
+	if messages[1].Role != llmstreamer.RoleDeveloper {
+		t.Fatalf("caller's messages must not be modified")
+	}
+
+	modern, err := NewRequestBody(Model("o3-mini"), messages, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+	if modern.Messages[1].Role != llmstreamer.RoleDeveloper {
+		t.Fatalf("expected developer role to be kept for newer models, got %q", modern.Messages[1].Role)
+	}
+}
+
+func TestNewRequestBody_Tools(t *testing.T) {
+	o := llmstreamer.NewOptions(
+		llmstreamer.WithTools(llmstreamer.Tool{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}),
+		llmstreamer.WithForcedTool("get_weather"),
+	)
+
+	p, err := NewRequestBody(ModelGPT4o, nil, o)
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	var got struct {
+		Tools []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name       string          `json:"name"`
+				Parameters json.RawMessage `json:"parameters"`
+			} `json:"function"`
+		} `json:"tools"`
+		ToolChoice struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "get_weather" {
+		t.Fatalf("unexpected tools: %s", b)
+	}
+	if got.ToolChoice.Type != "function" || got.ToolChoice.Function.Name != "get_weather" {
+		t.Fatalf("unexpected tool choice: %s", b)
+	}
+
+	p, _ = NewRequestBody(ModelGPT4o, nil, llmstreamer.NewOptions(llmstreamer.WithToolChoice(llmstreamer.ToolChoiceRequired)))
+	if p.ToolChoice != "required" {
+		t.Fatalf("expected tool_choice 'required', got %v", p.ToolChoice)
+	}
+}
+
+func TestTranslateMessages_ToolRoundTrip(t *testing.T) {
+	messages := []llmstreamer.Message{
+		{Role: llmstreamer.RoleUser, Content: "Weather in Paris?"},
+		{
+			Role:      llmstreamer.RoleAssistant,
+			ToolCalls: []llmstreamer.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		},
+		llmstreamer.ToolResult("call_1", "sunny"),
+	}
+
+	got := translateMessages(ModelGPT4o, messages)
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(got))
+	}
+	tc := got[1].ToolCalls
+	if len(tc) != 1 || tc[0].ID != "call_1" || tc[0].Type != "function" || tc[0].Function.Name != "get_weather" || tc[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls: %+v", tc)
+	}
+	if got[2].Role != llmstreamer.RoleTool || got[2].ToolCallID != "call_1" || got[2].Content != "sunny" {
+		t.Fatalf("unexpected tool result: %+v", got[2])
+	}
+}
+
+func TestProcessStream_ToolCalls(t *testing.T) {
+	body := "" +
+		`data: {"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"get_time","arguments":"{}"}}]},"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var starts []llmstreamer.ToolCall
+	deltas := map[string]string{}
+	var calls []llmstreamer.ToolCall
+	finished := false
+
+	cb := &llmstreamer.StreamCallbacks{
+		OnToolCallStart: func(c llmstreamer.ToolCall) { starts = append(starts, c) },
+		OnToolCallDelta: func(id, d string) { deltas[id] += d },
+		OnToolCall:      func(c llmstreamer.ToolCall) { calls = append(calls, c) },
+		OnFinish:        func(string) { finished = true },
+		OnError:         func(err error) { t.Fatalf("unexpected error: %v", err) },
+	}
+
+	processStream(resp, cb)
+
+	if len(starts) != 2 || starts[0].ID != "call_a" || starts[1].Name != "get_time" {
+		t.Fatalf("unexpected starts: %+v", starts)
+	}
+	if deltas["call_a"] != `{"city":"Paris"}` {
+		t.Fatalf("unexpected deltas: %v", deltas)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls emitted once, got %+v", calls)
+	}
+	if calls[0].Name != "get_weather" || calls[0].Arguments != `{"city":"Paris"}` || calls[1].ID != "call_b" {
+		t.Fatalf("unexpected tool calls: %+v", calls)
+	}
+	if !finished {
+		t.Fatalf("expected OnFinish to be called")
+	}
+}
+
+func TestNewRequestBody_ResponseFormat(t *testing.T) {
+	rf := llmstreamer.ResponseFormat{
+		Name:   "answer",
+		Schema: json.RawMessage(`{"type":"object","properties":{"value":{"type":"integer"}}}`),
+		Strict: true,
+	}
+
+	p, err := NewRequestBody(ModelGPT4o, nil, llmstreamer.NewOptions(llmstreamer.WithResponseFormat(rf)))
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	want := `"response_format":{"type":"json_schema","json_schema":{"name":"answer","schema":{"type":"object","properties":{"value":{"type":"integer"}}},"strict":true}}`
+	if !strings.Contains(string(b), want) {
+		t.Fatalf("expected %s in %s", want, b)
+	}
+}
+
+func TestProcessStream_Usage(t *testing.T) {
+	body := "" +
+		`data: {"id":"c1","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}],"usage":null}` + "\n\n" +
+		`data: {"id":"c1","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}` + "\n\n" +
+		`data: {"id":"c1","model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":1200,"completion_tokens":300,"total_tokens":1500,"prompt_tokens_details":{"cached_tokens":1000}}}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var usages []llmstreamer.Usage
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnUsage: func(u llmstreamer.Usage) { usages = append(usages, u) },
+		OnError: func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if len(usages) != 1 {
+		t.Fatalf("expected one usage report, got %d", len(usages))
+	}
+	u := usages[0]
+	if u.InputTokens != 200 || u.CacheReadTokens != 1000 || u.OutputTokens != 300 || u.CacheWriteTokens != 0 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+
+	want := (200*2.50 + 1000*1.25 + 300*10.00) / 1e6
+	if diff := u.Cost - want; diff > 1e-12 || diff < -1e-12 {
+		t.Fatalf("expected cost %v, got %v", want, u.Cost)
+	}
+}
+
+func TestNewRequestBody_IncludesUsage(t *testing.T) {
+	p, err := NewRequestBody(ModelGPT4o, nil, llmstreamer.Options{})
+	if err != nil {
+		t.Fatalf("NewRequestBody returned error: %v", err)
+	}
+
+	b, _ := json.Marshal(p)
+	if !strings.Contains(string(b), `"stream_options":{"include_usage":true}`) {
+		t.Fatalf("expected stream_options.include_usage: %s", b)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	if p, ok := PriceFor("gpt-4o-mini-2024-07-18"); !ok || p != prices[ModelGPT4oMini] {
+		t.Fatalf("expected gpt-4o-mini snapshot to use gpt-4o-mini pricing, got %+v %v", p, ok)
+	}
+	if p, ok := PriceFor("gpt-4o"); !ok || p != prices[ModelGPT4o] {
+		t.Fatalf("expected exact match, got %+v %v", p, ok)
+	}
+	if _, ok := PriceFor("llama3"); ok {
+		t.Fatalf("expected unknown model to have no price")
+	}
+}
+
+func TestSetPrice(t *testing.T) {
+	model := Model("ft:gpt-4o-mini:acme")
+	want := llmstreamer.Price{Input: 0.30, Output: 1.20}
+
+	// Streams look prices up while the table changes.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			PriceFor("gpt-4o-mini-2024-07-18")
+		}
+	}()
+	SetPrice(model, want)
+	<-done
+
+	if p, ok := PriceFor(string(model)); !ok || p != want {
+		t.Fatalf("expected the price set by SetPrice, got %+v %v", p, ok)
+	}
+}
+
+func TestProcessStream_FinishResult(t *testing.T) {
+	body := "" +
+		`data: {"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","system_fingerprint":"fp_abc","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","system_fingerprint":"fp_abc","choices":[{"index":0,"delta":{},"finish_reason":"length"}]}` + "\n\n" +
+		`data: {"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}` + "\n\n" +
+		`data: [DONE]` + "\n\n"
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	var result llmstreamer.FinishResult
+	var final string
+	processStream(resp, &llmstreamer.StreamCallbacks{
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnFinish:       func(f string) { final = f },
+	})
+
+	if final != "Hi" || result.Message != "Hi" {
+		t.Fatalf("unexpected final message: %q / %q", final, result.Message)
+	}
+	if result.StopReason != llmstreamer.StopReasonMaxTokens || result.RawStopReason != "length" {
+		t.Fatalf("unexpected stop reason: %+v", result)
+	}
+	if result.ResponseID != "chatcmpl-1" || result.Model != "gpt-4o-2024-08-06" || result.SystemFingerprint != "fp_abc" {
+		t.Fatalf("unexpected metadata: %+v", result)
+	}
+	if result.Usage == nil || result.Usage.OutputTokens != 1 {
+		t.Fatalf("expected usage in result, got %+v", result.Usage)
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	tests := map[string]llmstreamer.StopReason{
+		"stop":           llmstreamer.StopReasonEndTurn,
+		"length":         llmstreamer.StopReasonMaxTokens,
+		"tool_calls":     llmstreamer.StopReasonToolUse,
+		"function_call":  llmstreamer.StopReasonToolUse,
+		"content_filter": llmstreamer.StopReasonContentFilter,
+		"something_else": llmstreamer.StopReasonOther,
+	}
+	for raw, want := range tests {
+		if got := stopReason(raw); got != want {
+			t.Fatalf("stopReason(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestProcessStream_APIError(t *testing.T) {
+	header := make(http.Header)
+	header.Set("x-request-id", "req_123")
+	header.Set("Retry-After", "2")
+
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"Rate limit reached","type":"requests","param":null,"code":"rate_limit_exceeded"}}`)),
+	}
+
+	gotErr := processStream(resp, &llmstreamer.StreamCallbacks{})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.StatusCode != 429 || apiErr.Code != "rate_limit_exceeded" || apiErr.Message != "Rate limit reached" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if apiErr.RequestID != "req_123" || apiErr.RetryAfter != 2*time.Second {
+		t.Fatalf("unexpected header fields: %+v", apiErr)
+	}
+	if !errors.Is(gotErr, llmstreamer.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited")
+	}
+}
+
+func TestNewAPIError_ContextLength(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadRequest}
+	body := `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`
+
+	err := newAPIError(resp, []byte(body))
+	if !errors.Is(err, llmstreamer.ErrContextLength) {
+		t.Fatalf("expected ErrContextLength, got %v", err)
+	}
+
+	numeric := newAPIError(resp, []byte(`{"error":{"message":"bad","code":400}}`))
+	if numeric.Code != "400" {
+		t.Fatalf("expected numeric code to be converted, got %q", numeric.Code)
+	}
+
+	raw := newAPIError(resp, []byte(`upstream error`))
+	if raw.Body != "upstream error" || raw.Message != "" {
+		t.Fatalf("expected raw body for unparseable error, got %+v", raw)
+	}
+}
+
+func TestStreamChat_RetriesBeforeContent(t *testing.T) {
+	s := New("test-key", "")
+	s.Retry = &llmstreamer.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+
+	calls := 0
+	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+		}
+		if calls == 2 {
+			h := make(http.Header)
+			h.Set("x-ratelimit-reset-requests", "1ms")
+			h.Set("x-ratelimit-remaining-requests", "0")
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: h, Body: io.NopCloser(strings.NewReader(`{"error":{"message":"slow down","code":"rate_limit_exceeded"}}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`data: {"choices":[{"delta":{"content":"ok"}}]}` + "\n\n" + `data: [DONE]` + "\n\n"))}, nil
+	})
+
+	var final string
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if calls != 3 || final != "ok" {
+		t.Fatalf("calls = %d, final = %q", calls, final)
+	}
+}
+
+func TestStreamChat_ErrorChunk(t *testing.T) {
+	body := `data: {"id":"c1","choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n" +
+		`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error","param":null,"code":null}}` + "\n\n"
+
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+
+	var gotErr error
+	s := New("test-key", "")
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) { return resp, nil })
+
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { t.Fatalf("unexpected finish: %q", f) },
+		OnError:  func(err error) { gotErr = err },
+	})
+
+	var apiErr *llmstreamer.APIError
+	if !errors.As(gotErr, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", gotErr, gotErr)
+	}
+	if apiErr.StatusCode != 0 || apiErr.Type != "server_error" || apiErr.Code != "" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if !llmstreamer.IsRetryable(gotErr) {
+		t.Fatalf("expected server_error to be retryable")
+	}
+}
+
+func TestStreamChat_CustomClientBaseURLAndHeaders(t *testing.T) {
+	var gotPath, gotOrg, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotOrg = r.Header.Get("OpenAI-Organization")
+		gotAuth = r.Header.Get("Authorization")
+		io.WriteString(w, `data: {"choices":[{"delta":{"content":"ok"}}]}`+"\n\n"+`data: [DONE]`+"\n\n")
+	}))
+	defer srv.Close()
+
+	var roundTrips int
+	s := New("test-key", "")
+	s.BaseURL = srv.URL + "/proxy/v1/"
+	s.Header = http.Header{"Openai-Organization": {"org-123"}}
+	s.HTTPClient = &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		roundTrips++
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	var final string
+	s.StreamChat(context.Background(), nil, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if final != "ok" || roundTrips != 1 {
+		t.Fatalf("final = %q, round trips = %d", final, roundTrips)
+	}
+	if gotPath != "/proxy/v1/chat/completions" || gotOrg != "org-123" || gotAuth != "Bearer test-key" {
+		t.Fatalf("path = %q, org = %q, auth = %q", gotPath, gotOrg, gotAuth)
+	}
+}
+
+func TestStreamChat_CompatibleServers(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		model      string
+		responseID string
+	}{
+		{"ollama.sse", "qwen3:8b", "chatcmpl-412"},
+		{"vllm.sse", "Qwen/QwQ-32B", "chatcmpl-8d0c6a1b2f4e4a5c9b6e"},
+		{"lmstudio.sse", "deepseek-r1-distill-qwen-7b", "chatcmpl-w3ip9mfa3ahs7mqkd0ltmj"},
+		{"llamacpp.sse", "local", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			fixture, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var req RequestBody
+			var auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write(fixture)
+			}))
+			defer srv.Close()
+
+			var reasoning, content string
+			var result llmstreamer.FinishResult
+			s := NewCompatible(srv.URL+"/v1", tt.model)
+			s.StreamChat(context.Background(), []llmstreamer.Message{
+				{Role: llmstreamer.RoleDeveloper, Content: "Be brief."},
+				{Role: llmstreamer.RoleUser, Content: "Say hello"},
+			}, &llmstreamer.StreamCallbacks{
+				OnReasoning:    func(r string) { reasoning += r },
+				OnContent:      func(c string) { content += c },
+				OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+				OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+			})
+
+			if auth != "" {
+				t.Fatalf("expected no Authorization header, got %q", auth)
+			}
+			if string(req.Model) != tt.model || req.Messages[0].Role != llmstreamer.RoleSystem {
+				t.Fatalf("unexpected request: model %q, first role %q", req.Model, req.Messages[0].Role)
+			}
+			if content != "Hello there!" || result.Message != content {
+				t.Fatalf("content = %q, message = %q", content, result.Message)
+			}
+			if reasoning == "" || result.Reasoning != reasoning {
+				t.Fatalf("reasoning = %q, result reasoning = %q", reasoning, result.Reasoning)
+			}
+			if result.StopReason != llmstreamer.StopReasonEndTurn || result.ResponseID != tt.responseID {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestSupportsDeveloperRole(t *testing.T) {
+	for model, want := range map[Model]bool{
+		ModelGPT4o:            false,
+		"gpt-4o-2024-08-06":   false,
+		"gpt-4o-mini":         false,
+		"gpt-3.5-turbo-0125":  false,
+		"gpt-4-turbo-preview": false,
+		"gpt-4":               false,
+		"gpt-4-0613":          false,
+		"gpt-4.1":             true,
+		"gpt-4.1-2025-04-14":  true,
+		"gpt-5":               true,
+		"o1":                  true,
+		"o4-mini":             true,
+		"llama3.1:8b":         false,
+		"Qwen/QwQ-32B":        false,
+		"openhermes-2.5":      false,
+		"chatgpt-4o-latest":   true,
+	} {
+		if got := model.SupportsDeveloperRole(); got != want {
+			t.Errorf("%s: SupportsDeveloperRole() = %v, want %v", model, got, want)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`)
+			return
+		}
+		io.WriteString(w, `data: {"choices":[{"delta":{"content":"Hello"}}]}`+"\n\n"+
+			`data: {"choices":[{"delta":{"content":" there"},"finish_reason":"stop"}]}`+"\n\n"+
+			`data: [DONE]`+"\n\n")
+	}))
+	defer srv.Close()
+
+	s := New("test-key", "")
+	s.BaseURL = srv.URL
+
+	st, err := s.Stream(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []llmstreamer.EventType
+	for ev, err := range st.All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		types = append(types, ev.Type)
+		if ev.Type == llmstreamer.EventFinish && ev.Result.Message != "Hello there" {
+			t.Fatalf("unexpected result: %+v", ev.Result)
+		}
+	}
+	want := []llmstreamer.EventType{llmstreamer.EventContent, llmstreamer.EventContent, llmstreamer.EventFinish}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+
+	s.ApiKey = "wrong-key"
+	_, err = s.Stream(context.Background(), nil)
+	if !errors.Is(err, llmstreamer.ErrAuth) {
+		t.Fatalf("expected ErrAuth from Stream, got %v", err)
+	}
+}
+
+func TestProcessStream_SSEFraming(t *testing.T) {
+	// Keep-alive comments as sent by OpenRouter, CRLF line endings, a data
+	// field without a space and an event split over two data lines.
+	body := ": OPENROUTER PROCESSING\r\n\r\n" +
+		"data:{\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\r\n\r\n" +
+		"data: {\"choices\":[{\"delta\":\r\ndata: {\"content\":\"lo\"}}]}\r\n\r\n" +
+		"data: [DONE]\r\n\r\n"
+
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+
+	var contents []string
+	err := processStream(resp, &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { contents = append(contents, c) },
+		OnError:   func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(contents, []string{"Hel", "lo"}) {
+		t.Fatalf("unexpected contents: %q", contents)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.WriteString(w, `data: {"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n"+
+			`data: [DONE]`+"\n\n")
+	}))
+	defer srv.Close()
+
+	s := New("test-key", "")
+	s.BaseURL = srv.URL + "/v1"
+	s.Transport = llmstreamer.NewTransport(llmstreamer.TransportConfig{
+		TLSConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig,
+	})
+	defer s.Transport.CloseIdleConnections()
+
+	if err := s.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var final string
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, &llmstreamer.StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+		OnError:  func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if stats := s.Transport.Stats(); final != "Hi" || stats.NewConns != 1 || stats.ReusedConns != 1 {
+		t.Fatalf("final %q, stats %+v", final, stats)
+	}
+}
+
+func TestStreamChat_Stalled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `data: {"choices":[{"delta":{"content":"Hel"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := New("test-key", "")
+	s.BaseURL = srv.URL
+	s.Timeouts = &llmstreamer.Timeouts{Idle: 50 * time.Millisecond}
+
+	var content string
+	var gotErr error
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, &llmstreamer.StreamCallbacks{
+		OnContent: func(c string) { content += c },
+		OnError:   func(err error) { gotErr = err },
+	})
+
+	var timeoutErr *llmstreamer.TimeoutError
+	if !errors.Is(gotErr, llmstreamer.ErrStreamStalled) || !errors.As(gotErr, &timeoutErr) {
+		t.Fatalf("unexpected error: %v", gotErr)
+	}
+	if content != "Hel" || timeoutErr.Partial != "Hel" {
+		t.Fatalf("content %q, partial %q", content, timeoutErr.Partial)
+	}
+}
+
+func TestStreamChat_Stats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(30 * time.Millisecond)
+		for _, c := range []string{"Hel", "lo", "!"} {
+			io.WriteString(w, `data: {"choices":[{"delta":{"content":"`+c+`"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+		io.WriteString(w, `data: {"choices":[{"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`+"\n\n"+
+			`data: [DONE]`+"\n\n")
+	}))
+	defer srv.Close()
+
+	s := New("test-key", "")
+	s.BaseURL = srv.URL
+
+	var result llmstreamer.FinishResult
+	var stats llmstreamer.StreamStats
+	s.StreamChat(context.Background(), []llmstreamer.Message{{Role: llmstreamer.RoleUser, Content: "hi"}}, &llmstreamer.StreamCallbacks{
+		OnFinishResult: func(r llmstreamer.FinishResult) { result = r },
+		OnStats:        func(s llmstreamer.StreamStats) { stats = s },
+		OnError:        func(err error) { t.Fatalf("unexpected error: %v", err) },
+	})
+
+	if result.Stats == nil || *result.Stats != stats {
+		t.Fatalf("result stats %+v, hook stats %+v", result.Stats, stats)
+	}
+	if stats.TimeToHeaders <= 0 || stats.TimeToFirstToken < stats.TimeToHeaders+30*time.Millisecond {
+		t.Fatalf("unexpected first-token timing: %+v", stats)
+	}
+	if stats.Deltas != 3 || stats.OutputTokens != 3 || stats.InterTokenP50 < 9*time.Millisecond || stats.TokensPerSecond <= 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// prices holds the list prices used to compute Usage.Cost. It is read by
+// every stream, so changes go through SetPrice.
+var (
+	pricesMu sync.RWMutex
+	prices   = map[Model]llmstreamer.Price{
+		ModelGPT4o:      {Input: 2.50, Output: 10.00, CacheRead: 1.25},
+		ModelGPT4oMini:  {Input: 0.15, Output: 0.60, CacheRead: 0.075},
+		ModelGPT4Turbo:  {Input: 10.00, Output: 30.00},
+		ModelGPT35Turbo: {Input: 0.50, Output: 1.50},
+	}
+)
+
+// PriceFor looks up the price of a model. Dated snapshots such as
+// "gpt-4o-2024-08-06" match the longest model name they start with.
+func PriceFor(model string) (llmstreamer.Price, bool) {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+
+	if p, ok := prices[Model(model)]; ok {
+		return p, true
+	}
+
+	var best Model
+	for m := range prices {
+		if strings.HasPrefix(model, string(m)+"-") && len(m) > len(best) {
+			best = m
+		}
+	}
+	if best == "" {
+		return llmstreamer.Price{}, false
+	}
+	return prices[best], true
+}
+
+// SetPrice adds or replaces the price of a model, for example to match
+// negotiated rates. It is safe to call while streams are running.
+func SetPrice(model Model, price llmstreamer.Price) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices[model] = price
+}
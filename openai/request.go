@@ -1,12 +1,213 @@
 package openai
 
-import "github.com/alparslanyilmaaz/llmstreamer"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+const maxStopSequences = 4
 
 type RequestBody struct {
-	Model     Model                 `json:"model"`
-	Messages  []llmstreamer.Message `json:"messages"`
-	MaxTokens int                   `json:"max_tokens"`
-	Stream    bool                  `json:"stream"`
+	Model     Model     `json:"model"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+	// MaxCompletionTokens replaces MaxTokens for the reasoning models, which
+	// reject max_tokens.
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	Stop                []string        `json:"stop,omitempty"`
+	Seed                *int64          `json:"seed,omitempty"`
+	PresencePenalty     *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64        `json:"frequency_penalty,omitempty"`
+	Tools               []Tool          `json:"tools,omitempty"`
+	ToolChoice          interface{}     `json:"tool_choice,omitempty"`
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      bool            `json:"strict,omitempty"`
+}
+
+type Message struct {
+	Role       llmstreamer.Role `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []ToolCall       `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type Tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+}
+
+type Function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// NewRequestBody builds the chat completions request for messages and o. It is
+// exported for providers that share the OpenAI wire format.
+func NewRequestBody(model Model, messages []llmstreamer.Message, o llmstreamer.Options) (RequestBody, error) {
+	if err := validateOptions(o); err != nil {
+		return RequestBody{}, err
+	}
+
+	body := RequestBody{
+		Model:            model,
+		Messages:         translateMessages(model, messages),
+		Temperature:      o.Temperature,
+		TopP:             o.TopP,
+		Stop:             o.Stop,
+		Seed:             o.Seed,
+		PresencePenalty:  o.PresencePenalty,
+		FrequencyPenalty: o.FrequencyPenalty,
+		Tools:            translateTools(o.Tools),
+		ToolChoice:       translateToolChoice(o.ToolChoice),
+		ResponseFormat:   translateResponseFormat(o.ResponseFormat),
+		Stream:           true,
+		StreamOptions:    &StreamOptions{IncludeUsage: true},
+	}
+	// Without a limit the model decides when to stop.
+	if model.UsesMaxCompletionTokens() {
+		body.MaxCompletionTokens = o.MaxTokens
+	} else {
+		body.MaxTokens = o.MaxTokens
+	}
+	return body, nil
+}
+
+// translateMessages converts messages to the chat completions wire format,
+// rewriting developer messages as system messages for models that predate the
+// developer role.
+func translateMessages(model Model, messages []llmstreamer.Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		role := m.Role
+		if role == llmstreamer.RoleDeveloper && !model.SupportsDeveloperRole() {
+			role = llmstreamer.RoleSystem
+		}
+
+		msg := Message{
+			Role:       role,
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID,
+		}
+		for _, tc := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: tc.Name, Arguments: tc.Arguments},
+			})
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+func translateTools(tools []llmstreamer.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]Tool, len(tools))
+	for i, t := range tools {
+		out[i] = Tool{
+			Type: "function",
+			Function: Function{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		}
+	}
+	return out
+}
+
+func translateToolChoice(choice *llmstreamer.ToolChoice) interface{} {
+	if choice == nil {
+		return nil
+	}
+
+	if choice.Mode == llmstreamer.ToolChoiceTool {
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice.Name},
+		}
+	}
+	return string(choice.Mode)
+}
+
+func translateResponseFormat(rf *llmstreamer.ResponseFormat) *ResponseFormat {
+	if rf == nil {
+		return nil
+	}
+
+	return &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchema{
+			Name:        rf.Name,
+			Description: rf.Description,
+			Schema:      rf.Schema,
+			Strict:      rf.Strict,
+		},
+	}
+}
+
+func validateOptions(o llmstreamer.Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if o.TopK != nil {
+		return llmstreamer.Unsupported("top_k")
+	}
+
+	if o.Temperature != nil && *o.Temperature > 2 {
+		return fmt.Errorf("openai: temperature must be between 0 and 2, got %v", *o.Temperature)
+	}
+	if len(o.Stop) > maxStopSequences {
+		return fmt.Errorf("openai: at most %d stop sequences are allowed, got %d", maxStopSequences, len(o.Stop))
+	}
+	if err := validatePenalty("presence_penalty", o.PresencePenalty); err != nil {
+		return err
+	}
+	return validatePenalty("frequency_penalty", o.FrequencyPenalty)
+}
+
+func validatePenalty(name string, p *float64) error {
+	if p != nil && (*p < -2 || *p > 2) {
+		return fmt.Errorf("openai: %s must be between -2 and 2, got %v", name, *p)
+	}
+	return nil
 }
 
 type StreamEvent struct {
@@ -17,7 +218,69 @@ type StreamEvent struct {
 	ServiceTier       string   `json:"service_tier,omitempty"`
 	SystemFingerprint string   `json:"system_fingerprint,omitempty"`
 	Choices           []Choice `json:"choices"`
+	Usage             *Usage   `json:"usage,omitempty"`
 	Obfuscation       string   `json:"obfuscation,omitempty"`
+	// Error is set when the server fails after the stream has started.
+	Error *ErrorDetail `json:"error,omitempty"`
+
+	// PromptFilterResults is the Azure OpenAI content filter annotation
+	// for the prompt, left raw for the azureopenai package to decode.
+	PromptFilterResults json.RawMessage `json:"prompt_filter_results,omitempty"`
+}
+
+// updateResult records the response metadata carried by every chunk.
+func updateResult(r *llmstreamer.FinishResult, ev StreamEvent) {
+	if ev.ID != "" {
+		r.ResponseID = ev.ID
+	}
+	if ev.Model != "" {
+		r.Model = ev.Model
+	}
+	if ev.SystemFingerprint != "" {
+		r.SystemFingerprint = ev.SystemFingerprint
+	}
+}
+
+func stopReason(reason string) llmstreamer.StopReason {
+	switch reason {
+	case "stop":
+		return llmstreamer.StopReasonEndTurn
+	case "length":
+		return llmstreamer.StopReasonMaxTokens
+	case "tool_calls", "function_call":
+		return llmstreamer.StopReasonToolUse
+	case "content_filter":
+		return llmstreamer.StopReasonContentFilter
+	}
+	return llmstreamer.StopReasonOther
+}
+
+type Usage struct {
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// toUsage converts the reported usage. OpenAI counts cached tokens as part of
+// the prompt, so they are moved out of InputTokens.
+func (u Usage) toUsage(model string) llmstreamer.Usage {
+	usage := llmstreamer.Usage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+	}
+	if d := u.PromptTokensDetails; d != nil {
+		usage.CacheReadTokens = d.CachedTokens
+		usage.InputTokens -= d.CachedTokens
+	}
+	if price, ok := PriceFor(model); ok {
+		usage.Cost = price.Cost(usage)
+	}
+	return usage
 }
 
 type Choice struct {
@@ -25,9 +288,33 @@ type Choice struct {
 	Delta        Delta       `json:"delta"`
 	Logprobs     interface{} `json:"logprobs"`
 	FinishReason *string     `json:"finish_reason"`
+
+	// ContentFilterResults is the Azure OpenAI content filter annotation
+	// for this choice, left raw for the azureopenai package to decode.
+	ContentFilterResults json.RawMessage `json:"content_filter_results,omitempty"`
 }
 
 type Delta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+
+	// Reasoning models on vLLM and llama.cpp stream their reasoning in
+	// reasoning_content; Ollama and LM Studio use reasoning.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+}
+
+func (d Delta) reasoning() string {
+	if d.ReasoningContent != "" {
+		return d.ReasoningContent
+	}
+	return d.Reasoning
+}
+
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
 }
@@ -0,0 +1,175 @@
+package llmstreamer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedOption = errors.New("llmstreamer: option not supported by provider")
+
+// Options holds the per-request generation parameters. Pointer fields are nil
+// when unset so providers can leave them out of the request and fall back to
+// the API defaults.
+type Options struct {
+	MaxTokens        int
+	Temperature      *float64
+	TopP             *float64
+	TopK             *int
+	Stop             []string
+	Seed             *int64
+	PresencePenalty  *float64
+	FrequencyPenalty *float64
+
+	Tools      []Tool
+	ToolChoice *ToolChoice
+
+	ResponseFormat *ResponseFormat
+
+	// extensions holds the provider-specific options set with WithExtension.
+	extensions map[any]any
+}
+
+type Option func(*Options)
+
+func NewOptions(opts ...Option) Options {
+	var o Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+func WithMaxTokens(n int) Option {
+	return func(o *Options) { o.MaxTokens = n }
+}
+
+func WithTemperature(t float64) Option {
+	return func(o *Options) { o.Temperature = &t }
+}
+
+func WithTopP(p float64) Option {
+	return func(o *Options) { o.TopP = &p }
+}
+
+func WithTopK(k int) Option {
+	return func(o *Options) { o.TopK = &k }
+}
+
+func WithStop(sequences ...string) Option {
+	return func(o *Options) { o.Stop = append(o.Stop, sequences...) }
+}
+
+func WithSeed(seed int64) Option {
+	return func(o *Options) { o.Seed = &seed }
+}
+
+func WithPresencePenalty(p float64) Option {
+	return func(o *Options) { o.PresencePenalty = &p }
+}
+
+func WithFrequencyPenalty(p float64) Option {
+	return func(o *Options) { o.FrequencyPenalty = &p }
+}
+
+func WithTools(tools ...Tool) Option {
+	return func(o *Options) { o.Tools = append(o.Tools, tools...) }
+}
+
+func WithToolChoice(mode ToolChoiceMode) Option {
+	return func(o *Options) { o.ToolChoice = &ToolChoice{Mode: mode} }
+}
+
+// WithForcedTool makes the model call the named tool.
+func WithForcedTool(name string) Option {
+	return func(o *Options) { o.ToolChoice = &ToolChoice{Mode: ToolChoiceTool, Name: name} }
+}
+
+func WithResponseFormat(rf ResponseFormat) Option {
+	return func(o *Options) { o.ResponseFormat = &rf }
+}
+
+// WithExtension sets a provider-specific option under key. Providers offer
+// their own Option constructors built on it and use an unexported key type,
+// as with context values; other providers ignore the option.
+func WithExtension(key, value any) Option {
+	return func(o *Options) {
+		if o.extensions == nil {
+			o.extensions = make(map[any]any)
+		}
+		o.extensions[key] = value
+	}
+}
+
+// Extension returns the provider-specific option set under key, or nil.
+func (o Options) Extension(key any) any {
+	return o.extensions[key]
+}
+
+// Validate checks the constraints shared by every provider. Providers apply
+// their own range checks and reject options they do not support.
+func (o Options) Validate() error {
+	if o.MaxTokens < 0 {
+		return fmt.Errorf("llmstreamer: max tokens must not be negative, got %d", o.MaxTokens)
+	}
+	if o.Temperature != nil && *o.Temperature < 0 {
+		return fmt.Errorf("llmstreamer: temperature must not be negative, got %v", *o.Temperature)
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("llmstreamer: top_p must be between 0 and 1, got %v", *o.TopP)
+	}
+	if o.TopK != nil && *o.TopK < 1 {
+		return fmt.Errorf("llmstreamer: top_k must be positive, got %d", *o.TopK)
+	}
+	for _, s := range o.Stop {
+		if s == "" {
+			return errors.New("llmstreamer: stop sequences must not be empty")
+		}
+	}
+	if rf := o.ResponseFormat; rf != nil {
+		if rf.Name == "" {
+			return errors.New("llmstreamer: response format name must not be empty")
+		}
+		if !json.Valid(rf.Schema) {
+			return fmt.Errorf("llmstreamer: response format %q schema is not valid JSON", rf.Name)
+		}
+	}
+	return o.validateTools()
+}
+
+func (o Options) validateTools() error {
+	names := make(map[string]bool, len(o.Tools))
+	for _, t := range o.Tools {
+		if t.Name == "" {
+			return errors.New("llmstreamer: tool name must not be empty")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("llmstreamer: duplicate tool %q", t.Name)
+		}
+		if len(t.Parameters) > 0 && !json.Valid(t.Parameters) {
+			return fmt.Errorf("llmstreamer: tool %q parameters are not valid JSON", t.Name)
+		}
+		names[t.Name] = true
+	}
+
+	if o.ToolChoice == nil {
+		return nil
+	}
+
+	switch o.ToolChoice.Mode {
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+	case ToolChoiceTool:
+		if !names[o.ToolChoice.Name] {
+			return fmt.Errorf("llmstreamer: tool choice names unknown tool %q", o.ToolChoice.Name)
+		}
+	default:
+		return fmt.Errorf("llmstreamer: unknown tool choice mode %q", o.ToolChoice.Mode)
+	}
+	return nil
+}
+
+func Unsupported(option string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedOption, option)
+}
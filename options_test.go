@@ -0,0 +1,91 @@
+package llmstreamer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions(
+		WithMaxTokens(256),
+		WithTemperature(0.5),
+		WithTopP(0.9),
+		WithTopK(40),
+		WithStop("a", "b"),
+		WithSeed(7),
+		WithPresencePenalty(0.1),
+		WithFrequencyPenalty(-0.1),
+		nil,
+	)
+
+	if o.MaxTokens != 256 {
+		t.Fatalf("expected MaxTokens 256, got %d", o.MaxTokens)
+	}
+	if o.Temperature == nil || *o.Temperature != 0.5 {
+		t.Fatalf("unexpected Temperature: %v", o.Temperature)
+	}
+	if o.TopP == nil || *o.TopP != 0.9 {
+		t.Fatalf("unexpected TopP: %v", o.TopP)
+	}
+	if o.TopK == nil || *o.TopK != 40 {
+		t.Fatalf("unexpected TopK: %v", o.TopK)
+	}
+	if len(o.Stop) != 2 || o.Stop[0] != "a" || o.Stop[1] != "b" {
+		t.Fatalf("unexpected Stop: %v", o.Stop)
+	}
+	if o.Seed == nil || *o.Seed != 7 {
+		t.Fatalf("unexpected Seed: %v", o.Seed)
+	}
+	if o.PresencePenalty == nil || *o.PresencePenalty != 0.1 {
+		t.Fatalf("unexpected PresencePenalty: %v", o.PresencePenalty)
+	}
+	if o.FrequencyPenalty == nil || *o.FrequencyPenalty != -0.1 {
+		t.Fatalf("unexpected FrequencyPenalty: %v", o.FrequencyPenalty)
+	}
+}
+
+func TestWithExtension(t *testing.T) {
+	type key struct{}
+	type otherKey struct{}
+
+	o := NewOptions(WithExtension(key{}, "first"), WithExtension(key{}, "second"))
+	if got := o.Extension(key{}); got != "second" {
+		t.Fatalf("Extension(key) = %v", got)
+	}
+	if got := o.Extension(otherKey{}); got != nil {
+		t.Fatalf("Extension(otherKey) = %v", got)
+	}
+	if got := NewOptions().Extension(key{}); got != nil {
+		t.Fatalf("Extension on empty options = %v", got)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"valid", []Option{WithMaxTokens(10), WithTemperature(1), WithTopP(1), WithTopK(1)}, false},
+		{"negative max tokens", []Option{WithMaxTokens(-1)}, true},
+		{"negative temperature", []Option{WithTemperature(-0.1)}, true},
+		{"top_p above one", []Option{WithTopP(1.5)}, true},
+		{"zero top_k", []Option{WithTopK(0)}, true},
+		{"empty stop sequence", []Option{WithStop("")}, true},
+	}
+
+	for _, tt := range tests {
+		err := NewOptions(tt.opts...).Validate()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: expected error=%v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	err := Unsupported("seed")
+	if !errors.Is(err, ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption, got %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package partialjson turns a truncated JSON document into the longest valid
+// JSON document it can prove, so values can be decoded while they stream in.
+package partialjson
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Parser consumes a JSON document incrementally. Each byte is scanned once, so
+// feeding a long stream chunk by chunk costs O(n) in total.
+//
+// The parser is tolerant of text around the document: anything before the
+// first '{' or '[' (such as a Markdown code fence) and anything after the
+// top-level value is ignored.
+type Parser struct {
+	buf []byte
+	err error
+
+	started bool
+	done    bool
+
+	// stack holds the open containers, '{' or '['. expectKey is true at index
+	// i when the object at stack[i] expects a key next.
+	stack     []byte
+	expectKey []bool
+
+	// safe is the length of buf that is valid once the open containers are
+	// closed.
+	safe int
+
+	inString   bool
+	stringKey  bool
+	escapeAt   int // start of a pending escape sequence, or -1
+	inNumber   bool
+	inLiteral  bool
+	afterValue bool
+}
+
+func NewParser() *Parser {
+	return &Parser{escapeAt: -1}
+}
+
+func (p *Parser) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	for i, c := range b {
+		if p.done {
+			return len(b), nil
+		}
+		if err := p.scan(c); err != nil {
+			p.err = err
+			return i, err
+		}
+	}
+	return len(b), nil
+}
+
+func (p *Parser) WriteString(s string) (int, error) {
+	return p.Write([]byte(s))
+}
+
+// Done reports whether the top-level value is complete.
+func (p *Parser) Done() bool {
+	return p.done
+}
+
+func (p *Parser) Err() error {
+	return p.err
+}
+
+// Bytes returns the JSON seen so far without any repair.
+func (p *Parser) Bytes() []byte {
+	return p.buf
+}
+
+// Complete returns the longest valid JSON document that the input seen so far
+// proves: open strings and containers are closed and incomplete trailing
+// tokens (a key without a value, a partial literal or number, a dangling
+// comma) are dropped. It returns nil when no value has started yet.
+func (p *Parser) Complete() []byte {
+	if !p.started {
+		return nil
+	}
+	if p.done {
+		return append([]byte(nil), p.buf...)
+	}
+
+	var out []byte
+	switch {
+	case p.inString && !p.stringKey:
+		end := len(p.buf)
+		if p.escapeAt >= 0 {
+			end = p.escapeAt
+		}
+		end = trimPartialRune(p.buf, end)
+		out = make([]byte, 0, end+1+len(p.stack))
+		out = append(out, p.buf[:end]...)
+		out = append(out, '"')
+	case p.inNumber && isDigit(p.buf[len(p.buf)-1]),
+		p.inLiteral && p.checkLiteral() == nil:
+		out = make([]byte, 0, len(p.buf)+len(p.stack))
+		out = append(out, p.buf...)
+	default:
+		out = make([]byte, 0, p.safe+len(p.stack))
+		out = append(out, p.buf[:p.safe]...)
+	}
+
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i] == '{' {
+			out = append(out, '}')
+		} else {
+			out = append(out, ']')
+		}
+	}
+	return out
+}
+
+// Complete is a convenience wrapper that repairs a complete buffer at once.
+func Complete(b []byte) ([]byte, error) {
+	p := NewParser()
+	if _, err := p.Write(b); err != nil {
+		return nil, err
+	}
+	return p.Complete(), nil
+}
+
+func (p *Parser) scan(c byte) error {
+	if !p.started {
+		if c != '{' && c != '[' {
+			return nil
+		}
+		p.started = true
+	}
+
+	if p.inString {
+		p.buf = append(p.buf, c)
+		p.scanString(c)
+		return nil
+	}
+
+	if p.inNumber {
+		if isNumberByte(c) {
+			p.buf = append(p.buf, c)
+			return nil
+		}
+		if !isDigit(p.buf[len(p.buf)-1]) {
+			return fmt.Errorf("partialjson: invalid number at offset %d", len(p.buf))
+		}
+		p.inNumber = false
+		p.endValue()
+	}
+
+	if p.inLiteral {
+		if c >= 'a' && c <= 'z' {
+			p.buf = append(p.buf, c)
+			return nil
+		}
+		if err := p.checkLiteral(); err != nil {
+			return err
+		}
+		p.inLiteral = false
+		p.endValue()
+	}
+
+	p.buf = append(p.buf, c)
+
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		return nil
+	case '{', '[':
+		if err := p.startValue(); err != nil {
+			return err
+		}
+		p.stack = append(p.stack, c)
+		p.expectKey = append(p.expectKey, c == '{')
+		p.afterValue = false
+		p.safe = len(p.buf)
+	case '}', ']':
+		n := len(p.stack)
+		open := byte('{')
+		if c == ']' {
+			open = '['
+		}
+		if n == 0 || p.stack[n-1] != open {
+			return fmt.Errorf("partialjson: unexpected %q at offset %d", c, len(p.buf)-1)
+		}
+		p.stack = p.stack[:n-1]
+		p.expectKey = p.expectKey[:n-1]
+		p.endValue()
+		p.safe = len(p.buf)
+		if len(p.stack) == 0 {
+			p.done = true
+		}
+	case ',':
+		if !p.afterValue {
+			return fmt.Errorf("partialjson: unexpected ',' at offset %d", len(p.buf)-1)
+		}
+		p.afterValue = false
+		if n := len(p.stack); p.stack[n-1] == '{' {
+			p.expectKey[n-1] = true
+		}
+	case ':':
+		n := len(p.stack)
+		if p.stack[n-1] != '{' || !p.afterValue {
+			return fmt.Errorf("partialjson: unexpected ':' at offset %d", len(p.buf)-1)
+		}
+		p.afterValue = false
+	case '"':
+		n := len(p.stack)
+		p.stringKey = p.stack[n-1] == '{' && p.expectKey[n-1]
+		if !p.stringKey {
+			if err := p.startValue(); err != nil {
+				return err
+			}
+		} else if p.afterValue {
+			return fmt.Errorf("partialjson: unexpected '\"' at offset %d", len(p.buf)-1)
+		}
+		p.inString = true
+	case 't', 'f', 'n':
+		if err := p.startValue(); err != nil {
+			return err
+		}
+		p.inLiteral = true
+	default:
+		if c == '-' || isDigit(c) {
+			if err := p.startValue(); err != nil {
+				return err
+			}
+			p.inNumber = true
+			return nil
+		}
+		return fmt.Errorf("partialjson: unexpected %q at offset %d", c, len(p.buf)-1)
+	}
+	return nil
+}
+
+func (p *Parser) scanString(c byte) {
+	if p.escapeAt >= 0 {
+		n := len(p.buf) - p.escapeAt
+		// \" \\ \n ... are 2 bytes, \uXXXX is 6.
+		if p.buf[p.escapeAt+1] != 'u' || n == 6 {
+			p.escapeAt = -1
+		}
+		return
+	}
+
+	switch c {
+	case '\\':
+		p.escapeAt = len(p.buf) - 1
+	case '"':
+		p.inString = false
+		if p.stringKey {
+			n := len(p.stack)
+			p.expectKey[n-1] = false
+			p.afterValue = true
+			return
+		}
+		p.endValue()
+		p.safe = len(p.buf)
+	}
+}
+
+// startValue checks that a value may begin at the current position.
+func (p *Parser) startValue() error {
+	n := len(p.stack)
+	if n == 0 {
+		return nil
+	}
+	if p.afterValue || (p.stack[n-1] == '{' && p.expectKey[n-1]) {
+		return fmt.Errorf("partialjson: unexpected value at offset %d", len(p.buf)-1)
+	}
+	return nil
+}
+
+func (p *Parser) endValue() {
+	p.afterValue = true
+	p.safe = len(p.buf)
+}
+
+func (p *Parser) checkLiteral() error {
+	i := len(p.buf) - 1
+	for i >= 0 && p.buf[i] >= 'a' && p.buf[i] <= 'z' {
+		i--
+	}
+	switch string(p.buf[i+1:]) {
+	case "true", "false", "null":
+		return nil
+	}
+	return fmt.Errorf("partialjson: invalid literal %q", p.buf[i+1:])
+}
+
+// trimPartialRune drops a multi-byte UTF-8 sequence cut off at end.
+func trimPartialRune(b []byte, end int) int {
+	for i := end - 1; i >= 0 && i >= end-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:end]) {
+				return i
+			}
+			break
+		}
+	}
+	return end
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNumberByte(c byte) bool {
+	return isDigit(c) || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
@@ -0,0 +1,112 @@
+package partialjson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, ``},
+		{`Sure! `, ``},
+		{`{`, `{}`},
+		{`{"na`, `{}`},
+		{`{"name"`, `{}`},
+		{`{"name":`, `{}`},
+		{`{"name": "Ad`, `{"name": "Ad"}`},
+		{`{"name": "Ada"`, `{"name": "Ada"}`},
+		{`{"name": "Ada",`, `{"name": "Ada"}`},
+		{`{"name": "Ada", "age": 3`, `{"name": "Ada", "age": 3}`},
+		{`{"name": "Ada", "age": 3.`, `{"name": "Ada"}`},
+		{`{"name": "Ada", "age": -`, `{"name": "Ada"}`},
+		{`{"name": "Ada", "age": 1e`, `{"name": "Ada"}`},
+		{`{"ok": tr`, `{}`},
+		{`{"ok": true`, `{"ok": true}`},
+		{`{"ok": null, "n": 1`, `{"ok": null, "n": 1}`},
+		{`{"tags": ["a", "b`, `{"tags": ["a", "b"]}`},
+		{`{"tags": ["a", `, `{"tags": ["a"]}`},
+		{`{"items": [{"id": 1}, {"id"`, `{"items": [{"id": 1}, {}]}`},
+		{`{"s": "line\`, `{"s": "line"}`},
+		{`{"s": "quote\"`, `{"s": "quote\""}`},
+		{`{"s": "\u00`, `{"s": ""}`},
+		{`{"s": "é`, `{"s": "é"}`},
+		{"{\"s\": \"caf\xc3", `{"s": "caf"}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{`[1, 2`, `[1, 2]`},
+		{`[[1], [`, `[[1], []]`},
+	}
+
+	for _, tt := range tests {
+		got, err := Complete([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("Complete(%q) returned error: %v", tt.in, err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("Complete(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if len(got) > 0 && !json.Valid(got) {
+			t.Fatalf("Complete(%q) produced invalid JSON %q", tt.in, got)
+		}
+	}
+}
+
+func TestParser_EveryPrefixIsValid(t *testing.T) {
+	doc := `{"title": "Café \"Le Monde\"", "rating": 4.5, "open": false, "tags": ["a", "b\\c"], ` +
+		`"address": {"street": "Rue 1", "zip": null}, "scores": [-1, 2e3, 0.25], "note": "ünïcödé"}`
+
+	p := NewParser()
+	for i := 0; i < len(doc); i++ {
+		if _, err := p.Write([]byte{doc[i]}); err != nil {
+			t.Fatalf("Write failed at offset %d: %v", i, err)
+		}
+		got := p.Complete()
+		if !json.Valid(got) {
+			t.Fatalf("prefix %q completed to invalid JSON %q", doc[:i+1], got)
+		}
+	}
+
+	if !p.Done() {
+		t.Fatalf("expected document to be complete")
+	}
+	if string(p.Complete()) != doc {
+		t.Fatalf("expected completed document to equal input")
+	}
+}
+
+func TestParser_IgnoresTrailingText(t *testing.T) {
+	p := NewParser()
+	p.WriteString(`{"a": 1}`)
+	if _, err := p.WriteString("\n```\nDone!"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(p.Bytes()) != `{"a": 1}` {
+		t.Fatalf("unexpected bytes: %q", p.Bytes())
+	}
+}
+
+func TestParser_Errors(t *testing.T) {
+	for _, in := range []string{
+		`{"a": 1]`,
+		`[1 2]`,
+		`{"a" 1}`,
+		`{1: 2}`,
+		`[,]`,
+		`{"a": tru}`,
+		`[1.]`,
+		`[x]`,
+	} {
+		p := NewParser()
+		if _, err := p.WriteString(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+		if p.Err() == nil {
+			t.Fatalf("expected Err to be set for %q", in)
+		}
+		if _, err := p.WriteString("{}"); err == nil {
+			t.Fatalf("expected Write after error to fail for %q", in)
+		}
+	}
+}
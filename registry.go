@@ -0,0 +1,87 @@
+package llmstreamer
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Config carries the provider-independent settings used by Open to build a
+// Streamer. Model is passed through to the provider as-is; an empty value
+// selects the provider's default model. A nil Retry disables retries and nil
+// Timeouts leave the limits to the context.
+// HTTPClient, Transport, BaseURL and Header are optional and default to the
+// provider's own settings. APIVersion pins the API version of providers that
+// version their API separately from the model, the anthropic-version header
+// for Anthropic and the api-version parameter for Azure OpenAI; empty selects
+// the provider's default.
+type Config struct {
+	APIKey   string
+	Model    string
+	Retry    *RetryPolicy
+	Timeouts *Timeouts
+
+	HTTPClient *http.Client
+	Transport  *Transport
+	BaseURL    string
+	Header     http.Header
+	APIVersion string
+}
+
+type Factory func(cfg Config) (Streamer, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Factory)
+)
+
+// Register makes a provider available by name to Open. Provider packages call
+// it from init, so importing a provider package is enough to register it.
+// Register panics if factory is nil or the name is already registered.
+func Register(name string, factory Factory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if factory == nil {
+		panic("llmstreamer: Register factory is nil")
+	}
+	if _, dup := providers[name]; dup {
+		panic("llmstreamer: Register called twice for provider " + name)
+	}
+	providers[name] = factory
+}
+
+func Open(name string, cfg Config) (Streamer, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("llmstreamer: unknown provider %q (forgotten import?)", name)
+	}
+	return factory(cfg)
+}
+
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetHeaders copies extra into h, replacing any values already set for the
+// same keys. Provider packages use it to apply user-supplied headers.
+func SetHeaders(h, extra http.Header) {
+	for key, values := range extra {
+		h.Del(key)
+		for _, v := range values {
+			h.Add(key, v)
+		}
+	}
+}
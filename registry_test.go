@@ -0,0 +1,124 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type stubStreamer struct {
+	cfg Config
+}
+
+func (s *stubStreamer) StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) {
+	if cb != nil && cb.OnFinish != nil {
+		cb.OnFinish(s.cfg.Model)
+	}
+}
+
+func TestRegisterAndOpen(t *testing.T) {
+	Register("stub-open", func(cfg Config) (Streamer, error) {
+		return &stubStreamer{cfg: cfg}, nil
+	})
+
+	s, err := Open("stub-open", Config{APIKey: "k", Model: "m"})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	var final string
+	s.StreamChat(context.Background(), nil, &StreamCallbacks{
+		OnFinish: func(f string) { final = f },
+	})
+	if final != "m" {
+		t.Fatalf("expected factory to receive model 'm', got %q", final)
+	}
+
+	found := false
+	for _, name := range Providers() {
+		if name == "stub-open" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected Providers to list 'stub-open', got %v", Providers())
+	}
+}
+
+func TestOpen_PassesConfig(t *testing.T) {
+	var got Config
+	Register("stub-config", func(cfg Config) (Streamer, error) {
+		got = cfg
+		return &stubStreamer{cfg: cfg}, nil
+	})
+
+	want := Config{
+		APIKey:     "k",
+		BaseURL:    "https://proxy.example.com",
+		Header:     http.Header{"X-Team": {"search"}},
+		APIVersion: "2024-01-01",
+	}
+	if _, err := Open("stub-config", want); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if got.APIVersion != want.APIVersion || got.BaseURL != want.BaseURL || got.Header.Get("X-Team") != "search" {
+		t.Fatalf("factory received %+v, want %+v", got, want)
+	}
+}
+
+func TestOpen_UnknownProvider(t *testing.T) {
+	_, err := Open("does-not-exist", Config{})
+	if err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+	if !strings.Contains(err.Error(), "does-not-exist") {
+		t.Fatalf("expected error to name the provider, got: %v", err)
+	}
+}
+
+func TestOpen_FactoryError(t *testing.T) {
+	want := errors.New("bad config")
+	Register("stub-error", func(cfg Config) (Streamer, error) { return nil, want })
+
+	if _, err := Open("stub-error", Config{}); !errors.Is(err, want) {
+		t.Fatalf("expected factory error, got %v", err)
+	}
+}
+
+func TestRegister_DuplicatePanics(t *testing.T) {
+	factory := func(cfg Config) (Streamer, error) { return &stubStreamer{}, nil }
+	Register("stub-dup", factory)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on duplicate registration")
+		}
+	}()
+	Register("stub-dup", factory)
+}
+
+func TestRegister_NilFactoryPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on nil factory")
+		}
+	}()
+	Register("stub-nil", nil)
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer a"}, "Content-Type": {"application/json"}}
+	SetHeaders(h, http.Header{"Authorization": {"Bearer b"}, "X-Extra": {"1", "2"}})
+
+	if got := h.Get("Authorization"); got != "Bearer b" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if got := h.Values("X-Extra"); len(got) != 2 {
+		t.Fatalf("X-Extra = %v", got)
+	}
+	if got := h.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries failed requests with jittered exponential backoff.
+// Requests are only retried while nothing has been delivered to the
+// callbacks, so a retry never repeats content the caller has already seen.
+//
+// A nil *RetryPolicy makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction, between 0 and 1.
+	Jitter float64
+
+	// OnAttempt, if set, is called after every failed attempt.
+	OnAttempt func(attempt RetryAttempt)
+}
+
+type RetryAttempt struct {
+	// Attempt is the 1-based number of the attempt that failed.
+	Attempt int
+	Err     error
+	// Retrying reports whether another attempt follows after Delay.
+	Retrying bool
+	Delay    time.Duration
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Do runs attempt until it succeeds or fails for good. attempt receives
+// callbacks that forward to cb and record whether content, reasoning or tool
+// calls have been delivered; once they have, errors are returned without
+// retrying.
+func (p *RetryPolicy) Do(ctx context.Context, cb *StreamCallbacks, attempt func(cb *StreamCallbacks) error) error {
+	var delivered bool
+
+	tracked := &StreamCallbacks{}
+	if cb != nil {
+		*tracked = *cb
+	}
+	tracked.OnContent = func(content string) {
+		delivered = true
+		cb.EmitContent(content)
+	}
+	tracked.OnReasoning = func(reasoning string) {
+		delivered = true
+		cb.EmitReasoning(reasoning)
+	}
+	tracked.OnToolCallStart = func(call ToolCall) {
+		delivered = true
+		cb.EmitToolCallStart(call)
+	}
+
+	for n := 1; ; n++ {
+		err := attempt(tracked)
+		if err == nil {
+			return nil
+		}
+
+		if p == nil || n >= p.MaxAttempts || delivered || ctx.Err() != nil || !IsRetryable(err) {
+			p.notify(RetryAttempt{Attempt: n, Err: err})
+			return err
+		}
+
+		delay := p.backoff(n, err)
+		p.notify(RetryAttempt{Attempt: n, Err: err, Retrying: true, Delay: delay})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) notify(a RetryAttempt) {
+	if p != nil && p.OnAttempt != nil {
+		p.OnAttempt(a)
+	}
+}
+
+// backoff returns the delay before the attempt after n. A delay requested by
+// the provider takes precedence over the computed one.
+func (p *RetryPolicy) backoff(n int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(n-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// IsRetryable reports whether err is worth retrying: network failures
+// (net.Error, connection resets and refusals, and responses cut short),
+// stalled streams and first-token timeouts, and rate limit, overload and
+// transient server errors (HTTP 429, 500, 502, 503 and 529), including the
+// same failures reported mid-stream. Context cancellation, configuration,
+// encoding and TLS certificate errors are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway:
+			return true
+		}
+		return errors.Is(apiErr, ErrRateLimited) || errors.Is(apiErr, ErrOverloaded) ||
+			apiErr.Type == "api_error" || apiErr.Type == "server_error" ||
+			apiErr.Type == "InternalServerException" || apiErr.Type == "ModelStreamErrorException"
+	}
+
+	if errors.Is(err, ErrStreamStalled) || errors.Is(err, ErrFirstTokenTimeout) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	// http.Client wraps every failure in a *url.Error, which is a net.Error
+	// whatever its cause, so only the cause is considered.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// ParseRateLimitReset reads the rate limit reset headers sent with a 429
+// response (OpenAI's x-ratelimit-reset-* durations and Anthropic's
+// anthropic-ratelimit-*-reset timestamps) and returns the time until the
+// exhausted limit resets. It returns 0 if no reset header is present.
+func ParseRateLimitReset(h http.Header) time.Duration {
+	var exhausted, any time.Duration
+
+	for key, values := range h {
+		if len(values) == 0 {
+			continue
+		}
+		key = strings.ToLower(key)
+
+		var d time.Duration
+		var remainingKey string
+		switch {
+		case strings.HasPrefix(key, "x-ratelimit-reset-"):
+			parsed, err := time.ParseDuration(values[0])
+			if err != nil {
+				continue
+			}
+			d = parsed
+			remainingKey = "x-ratelimit-remaining-" + strings.TrimPrefix(key, "x-ratelimit-reset-")
+		case strings.HasPrefix(key, "anthropic-ratelimit-") && strings.HasSuffix(key, "-reset"):
+			t, err := time.Parse(time.RFC3339, values[0])
+			if err != nil {
+				continue
+			}
+			d = time.Until(t)
+			remainingKey = strings.TrimSuffix(key, "-reset") + "-remaining"
+		default:
+			continue
+		}
+
+		if d <= 0 {
+			continue
+		}
+		if d > any {
+			any = d
+		}
+		if n, err := strconv.Atoi(h.Get(remainingKey)); err == nil && n == 0 && d > exhausted {
+			exhausted = d
+		}
+	}
+
+	if exhausted > 0 {
+		return exhausted
+	}
+	return any
+}
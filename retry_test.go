@@ -0,0 +1,220 @@
+package llmstreamer
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func TestRetryPolicy_RetriesUntilSuccess(t *testing.T) {
+	p := testRetryPolicy()
+	var seen []RetryAttempt
+	p.OnAttempt = func(a RetryAttempt) { seen = append(seen, a) }
+
+	calls := 0
+	var content string
+	err := p.Do(context.Background(), &StreamCallbacks{OnContent: func(c string) { content += c }}, func(cb *StreamCallbacks) error {
+		calls++
+		if calls < 3 {
+			return &APIError{StatusCode: http.StatusServiceUnavailable}
+		}
+		cb.EmitContent("ok")
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 || content != "ok" {
+		t.Fatalf("calls = %d, content = %q", calls, content)
+	}
+	if len(seen) != 2 || !seen[0].Retrying || seen[0].Attempt != 1 || seen[1].Attempt != 2 {
+		t.Fatalf("unexpected attempts: %+v", seen)
+	}
+}
+
+func TestRetryPolicy_GivesUp(t *testing.T) {
+	p := testRetryPolicy()
+	var last RetryAttempt
+	p.OnAttempt = func(a RetryAttempt) { last = a }
+
+	calls := 0
+	err := p.Do(context.Background(), nil, func(cb *StreamCallbacks) error {
+		calls++
+		return syscall.ECONNRESET
+	})
+
+	if err == nil || calls != 3 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+	if last.Attempt != 3 || last.Retrying {
+		t.Fatalf("unexpected final attempt: %+v", last)
+	}
+}
+
+func TestRetryPolicy_NoRetryAfterContent(t *testing.T) {
+	calls := 0
+	err := testRetryPolicy().Do(context.Background(), nil, func(cb *StreamCallbacks) error {
+		calls++
+		cb.EmitContent("partial")
+		return syscall.ECONNRESET
+	})
+
+	if err == nil || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestRetryPolicy_NotRetryable(t *testing.T) {
+	calls := 0
+	err := testRetryPolicy().Do(context.Background(), nil, func(cb *StreamCallbacks) error {
+		calls++
+		return &APIError{StatusCode: http.StatusBadRequest}
+	})
+
+	if err == nil || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestRetryPolicy_Nil(t *testing.T) {
+	var p *RetryPolicy
+	calls := 0
+	err := p.Do(context.Background(), nil, func(cb *StreamCallbacks) error {
+		calls++
+		return syscall.ECONNRESET
+	})
+
+	if err == nil || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestRetryPolicy_HonorsRetryAfter(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}
+	var delay time.Duration
+	p.OnAttempt = func(a RetryAttempt) {
+		if a.Retrying {
+			delay = a.Delay
+		}
+	}
+
+	calls := 0
+	err := p.Do(context.Background(), nil, func(cb *StreamCallbacks) error {
+		calls++
+		if calls == 1 {
+			return &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Millisecond}
+		}
+		return nil
+	})
+
+	if err != nil || delay != 2*time.Millisecond {
+		t.Fatalf("err = %v, delay = %v", err, delay)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2, Jitter: 0.5}
+	err := error(syscall.ECONNRESET)
+
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		got := p.backoff(n, err)
+		if got < want/2 || got > want*3/2 {
+			t.Fatalf("backoff(%d) = %v, want %v ± 50%%", n, got, want)
+		}
+	}
+}
+
+func TestRetryPolicy_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+	p.OnAttempt = func(RetryAttempt) { cancel() }
+
+	calls := 0
+	err := p.Do(ctx, nil, func(cb *StreamCallbacks) error {
+		calls++
+		return &APIError{StatusCode: http.StatusBadGateway}
+	})
+
+	if err == nil || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Post", URL: "https://api.example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{fmt.Errorf("read failed: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("read failed: %w", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}), true},
+		{&TimeoutError{Err: ErrStreamStalled}, true},
+		{&TimeoutError{Err: ErrFirstTokenTimeout}, true},
+		{&TimeoutError{Err: ErrStreamTimeout}, false},
+		{&url.Error{Op: "Post", URL: "api.example.com", Err: errors.New(`unsupported protocol scheme ""`)}, false},
+		{&url.Error{Op: "Post", URL: "https://api.example.com", Err: x509.UnknownAuthorityError{}}, false},
+		{&json.UnsupportedValueError{Str: "NaN"}, false},
+		{errors.New("bedrock: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set"), false},
+		{context.Canceled, false},
+		{&APIError{StatusCode: 429}, true},
+		{&APIError{StatusCode: 429, Code: "insufficient_quota"}, false},
+		{&APIError{StatusCode: 500}, true},
+		{&APIError{StatusCode: 502}, true},
+		{&APIError{StatusCode: 503}, true},
+		{&APIError{StatusCode: 529}, true},
+		{&APIError{StatusCode: 400}, false},
+		{&APIError{StatusCode: 401}, false},
+		{&APIError{Type: "overloaded_error"}, true},
+		{&APIError{Type: "invalid_request_error"}, false},
+		{&APIError{Type: "ModelStreamErrorException"}, true},
+		{&APIError{Type: "ValidationException"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	h := make(http.Header)
+	h.Set("x-ratelimit-reset-requests", "1s")
+	h.Set("x-ratelimit-remaining-requests", "10")
+	h.Set("x-ratelimit-reset-tokens", "6m0s")
+	h.Set("x-ratelimit-remaining-tokens", "0")
+
+	if got := ParseRateLimitReset(h); got != 6*time.Minute {
+		t.Fatalf("got %v, want 6m", got)
+	}
+
+	h.Set("x-ratelimit-remaining-tokens", "5")
+	h.Set("x-ratelimit-remaining-requests", "0")
+	if got := ParseRateLimitReset(h); got != time.Second {
+		t.Fatalf("got %v, want 1s", got)
+	}
+
+	a := make(http.Header)
+	a.Set("anthropic-ratelimit-requests-reset", time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+	if got := ParseRateLimitReset(a); got <= 25*time.Second || got > 30*time.Second {
+		t.Fatalf("got %v, want about 30s", got)
+	}
+
+	if got := ParseRateLimitReset(make(http.Header)); got != 0 {
+		t.Fatalf("got %v, want 0", got)
+	}
+}
@@ -0,0 +1,63 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// benchStream is 100k events shaped like an Anthropic text delta.
+var benchStream = []byte(strings.Repeat("event: content_block_delta\n"+`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" token"}}`+"\n\n", 100_000))
+
+func BenchmarkDecoder(b *testing.B) {
+	stream := benchStream
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(bytes.NewReader(stream))
+		for {
+			if _, err := d.Next(); err != nil {
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+				break
+			}
+		}
+		d.Release()
+	}
+}
+
+// BenchmarkLegacyScanner reads the same stream the way it was read before the
+// decoder: a bufio.Scanner over the lines, copying the fields of each event.
+// It is the baseline for BenchmarkDecoder.
+func BenchmarkLegacyScanner(b *testing.B) {
+	stream := benchStream
+
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var ev Event
+		sc := bufio.NewScanner(bytes.NewReader(stream))
+		for sc.Scan() {
+			line := sc.Bytes()
+			switch {
+			case len(line) == 0:
+				ev = Event{}
+			case bytes.HasPrefix(line, []byte("event: ")):
+				ev.Type = string(line[len("event: "):])
+			case bytes.HasPrefix(line, []byte("data: ")):
+				ev.Data = append(ev.Data, line[len("data: "):]...)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package sse decodes server-sent event streams as specified by the WHATWG
+// HTML standard (https://html.spec.whatwg.org/multipage/server-sent-events.html).
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event is a dispatched event. Type is "message" unless the stream named the
+// event with an "event:" field. Data is the event's data lines joined with
+// "\n". ID is the last event ID seen so far, which carries over to later
+// events until the stream sets a new one.
+type Event struct {
+	Type string
+	Data []byte
+	ID   string
+}
+
+const (
+	// maxLineSize bounds the memory a single line may use.
+	maxLineSize = 16 << 20
+	readSize    = 4096
+	// maxPooledSize bounds the buffers kept for reuse, so one huge event does
+	// not pin its memory for the life of the program.
+	maxPooledSize = 64 << 10
+)
+
+var ErrLineTooLong = errors.New("sse: line too long")
+
+var errReleased = errors.New("sse: decoder used after Release")
+
+// buffers are the read and data buffers of a decoder, pooled between streams.
+type buffers struct {
+	read, data []byte
+}
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return &buffers{read: make([]byte, readSize)}
+	},
+}
+
+// Decoder reads events from a stream. Lines may end with CRLF, LF or CR.
+// Comments, unknown fields and events without data are skipped, and an event
+// that is not terminated by a blank line before the end of the stream is
+// discarded, as the specification requires, unless DispatchAtEOF is set.
+type Decoder struct {
+	// DispatchAtEOF makes Next return the event left unterminated when the
+	// stream ends, including a final line without a line terminator, for
+	// servers that close the stream without the closing blank line.
+	DispatchAtEOF bool
+
+	r   io.Reader
+	err error
+
+	bufs       *buffers
+	buf        []byte
+	start, end int
+	// skipLF is set after a line ended with CR at the end of buf, so that the
+	// LF of a CRLF split across reads is not taken for an empty line.
+	skipLF bool
+	// bomChecked is set once the optional leading byte order mark has been
+	// handled.
+	bomChecked bool
+
+	eventType string
+	lastType  string
+	data      []byte
+	lastID    string
+	retry     time.Duration
+}
+
+// NewDecoder returns a decoder reading from r. Call Release once the decoder
+// is no longer needed to let later decoders reuse its buffers.
+func NewDecoder(r io.Reader) *Decoder {
+	bufs := bufferPool.Get().(*buffers)
+	return &Decoder{r: r, bufs: bufs, buf: bufs.read, data: bufs.data[:0]}
+}
+
+// Release returns the decoder's buffers to a pool. Event.Data from earlier
+// calls to Next must not be used afterwards, and Next returns an error.
+func (d *Decoder) Release() {
+	if d.bufs == nil {
+		return
+	}
+	if cap(d.buf) <= maxPooledSize && cap(d.data) <= maxPooledSize {
+		d.bufs.read, d.bufs.data = d.buf, d.data[:0]
+		bufferPool.Put(d.bufs)
+	}
+	d.bufs, d.buf, d.data = nil, nil, nil
+	d.start, d.end = 0, 0
+	d.err = errReleased
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream and
+// any other read error as it is. Event.Data is only valid until the next call
+// to Next.
+func (d *Decoder) Next() (Event, error) {
+	d.data = d.data[:0]
+	d.eventType = ""
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			if err == io.EOF && d.DispatchAtEOF && len(d.data) > 0 {
+				return d.dispatch(), nil
+			}
+			return Event{}, err
+		}
+
+		if len(line) == 0 {
+			if len(d.data) == 0 {
+				d.eventType = ""
+				continue
+			}
+			return d.dispatch(), nil
+		}
+
+		d.processField(line)
+	}
+}
+
+func (d *Decoder) dispatch() Event {
+	ev := Event{Type: d.eventType, Data: d.data[:len(d.data)-1], ID: d.lastID}
+	if ev.Type == "" {
+		ev.Type = "message"
+	}
+	return ev
+}
+
+// Retry returns the reconnection time last set by a "retry:" field, or 0.
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+func (d *Decoder) processField(line []byte) {
+	if line[0] == ':' {
+		return
+	}
+
+	field, value := line, []byte(nil)
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], line[i+1:]
+		if len(value) > 0 && value[0] == ' ' {
+			value = value[1:]
+		}
+	}
+
+	switch string(field) {
+	case "event":
+		// Most streams name every event from a small set, so the name is
+		// only allocated when it changes.
+		if string(value) != d.lastType {
+			d.lastType = string(value)
+		}
+		d.eventType = d.lastType
+	case "data":
+		d.data = append(d.data, value...)
+		d.data = append(d.data, '\n')
+	case "id":
+		if bytes.IndexByte(value, 0) < 0 {
+			d.lastID = string(value)
+		}
+	case "retry":
+		if isDigits(value) {
+			if ms, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var bom = []byte("\xef\xbb\xbf")
+
+// readLine returns the next line without its terminator. The line is only
+// valid until the next call. A final line without a terminator is dropped
+// unless DispatchAtEOF is set.
+func (d *Decoder) readLine() ([]byte, error) {
+	for {
+		if d.skipLF && d.start < d.end {
+			if d.buf[d.start] == '\n' {
+				d.start++
+			}
+			d.skipLF = false
+		}
+
+		if !d.bomChecked {
+			d.checkBOM()
+		}
+
+		if d.bomChecked {
+			if i := bytes.IndexAny(d.buf[d.start:d.end], "\r\n"); i >= 0 {
+				line := d.buf[d.start : d.start+i]
+				cr := d.buf[d.start+i] == '\r'
+				d.start += i + 1
+				if cr {
+					if d.start < d.end {
+						if d.buf[d.start] == '\n' {
+							d.start++
+						}
+					} else {
+						d.skipLF = true
+					}
+				}
+				return line, nil
+			}
+		}
+
+		if d.err == io.EOF && d.DispatchAtEOF && d.bomChecked && d.start < d.end {
+			line := d.buf[d.start:d.end]
+			d.start = d.end
+			return line, nil
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if err := d.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checkBOM skips a UTF-8 byte order mark at the start of the stream once
+// enough bytes have arrived to tell.
+func (d *Decoder) checkBOM() {
+	b := d.buf[d.start:d.end]
+	switch {
+	case bytes.HasPrefix(b, bom):
+		d.start += len(bom)
+	case len(b) < len(bom) && bytes.HasPrefix(bom, b) && d.err == nil:
+		return
+	}
+	d.bomChecked = true
+}
+
+// fill reads more of the stream into buf, making room by moving the unread
+// bytes to the front or growing the buffer.
+func (d *Decoder) fill() error {
+	if d.start > 0 {
+		n := copy(d.buf, d.buf[d.start:d.end])
+		d.start, d.end = 0, n
+	}
+	if d.end == len(d.buf) {
+		if len(d.buf) >= maxLineSize {
+			return ErrLineTooLong
+		}
+		grown := make([]byte, 2*len(d.buf))
+		copy(grown, d.buf[:d.end])
+		d.buf = grown
+	}
+
+	n, err := d.r.Read(d.buf[d.end:])
+	d.end += n
+	d.err = err
+	return nil
+}
@@ -0,0 +1,183 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// event is an Event with its data copied, since Event.Data is reused.
+type event struct {
+	Type, Data, ID string
+}
+
+func decodeAll(t *testing.T, r io.Reader) ([]event, *Decoder, error) {
+	t.Helper()
+	d := NewDecoder(r)
+	var events []event
+	for {
+		ev, err := d.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return events, d, err
+		}
+		events = append(events, event{ev.Type, string(ev.Data), ev.ID})
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []event
+	}{
+		{
+			name:  "data",
+			input: "data: {\"a\":1}\n\n",
+			want:  []event{{"message", `{"a":1}`, ""}},
+		},
+		{
+			name:  "event name",
+			input: "event: content_block_delta\ndata: {}\n\nevent: ping\ndata: {}\n\n",
+			want:  []event{{"content_block_delta", "{}", ""}, {"ping", "{}", ""}},
+		},
+		{
+			name:  "multi-line data",
+			input: "data: first\ndata: second\ndata\n\n",
+			want:  []event{{"message", "first\nsecond\n", ""}},
+		},
+		{
+			name:  "no space after colon",
+			input: "data:tight\n\ndata:  two spaces\n\n",
+			want:  []event{{"message", "tight", ""}, {"message", " two spaces", ""}},
+		},
+		{
+			name:  "comments and unknown fields",
+			input: ": keep-alive\n\nfoo: bar\ndata: x\n: between\n\n",
+			want:  []event{{"message", "x", ""}},
+		},
+		{
+			name:  "event without data is not dispatched",
+			input: "event: ping\n\ndata: x\n\n",
+			want:  []event{{"message", "x", ""}},
+		},
+		{
+			name:  "id carries over",
+			input: "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\nid: bad\x00id\ndata: d\n\n",
+			want:  []event{{"message", "a", "1"}, {"message", "b", "1"}, {"message", "c", ""}, {"message", "d", ""}},
+		},
+		{
+			name:  "CRLF and CR line endings",
+			input: "data: a\r\n\r\ndata: b\r\rdata: c\n\r\n",
+			want:  []event{{"message", "a", ""}, {"message", "b", ""}, {"message", "c", ""}},
+		},
+		{
+			name:  "byte order mark",
+			input: "\xef\xbb\xbfdata: x\n\n",
+			want:  []event{{"message", "x", ""}},
+		},
+		{
+			name:  "unterminated event is discarded",
+			input: "data: a\n\ndata: b\n",
+			want:  []event{{"message", "a", ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reading one byte at a time splits every CRLF and the byte
+			// order mark across reads.
+			for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				got, _, err := decodeAll(t, r)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_DispatchAtEOF(t *testing.T) {
+	tests := map[string][]event{
+		"data: a\n\ndata: b\n":         {{"message", "a", ""}, {"message", "b", ""}},
+		"data: a\n\nevent: x\ndata: b": {{"message", "a", ""}, {"x", "b", ""}},
+		"data: a\r\n\r\ndata: b\r":     {{"message", "a", ""}, {"message", "b", ""}},
+		"data: a\n\nevent: x\n":        {{"message", "a", ""}},
+		"data: a\n\n":                  {{"message", "a", ""}},
+	}
+	for input, want := range tests {
+		for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+			d := NewDecoder(r)
+			d.DispatchAtEOF = true
+			var got []event
+			for {
+				ev, err := d.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%q: unexpected error: %v", input, err)
+				}
+				got = append(got, event{ev.Type, string(ev.Data), ev.ID})
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%q: got %q, want %q", input, got, want)
+			}
+		}
+	}
+}
+
+func TestDecoder_Retry(t *testing.T) {
+	_, d, err := decodeAll(t, strings.NewReader("retry: 1500\ndata: a\n\nretry: soon\ndata: b\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Retry() != 1500*time.Millisecond {
+		t.Fatalf("Retry() = %v", d.Retry())
+	}
+}
+
+func TestDecoder_LongLine(t *testing.T) {
+	data := strings.Repeat("x", 3*readSize)
+	got, _, err := decodeAll(t, strings.NewReader("data: "+data+"\n\n"))
+	if err != nil || len(got) != 1 || got[0].Data != data {
+		t.Fatalf("unexpected result: %d events, err %v", len(got), err)
+	}
+}
+
+func TestDecoder_ReadError(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader("data: a\n\ndata: b"), iotest.ErrReader(boom))
+
+	got, _, err := decodeAll(t, r)
+	if !errors.Is(err, boom) || len(got) != 1 {
+		t.Fatalf("got %q, err %v", got, err)
+	}
+}
+
+func TestDecoder_Release(t *testing.T) {
+	d := NewDecoder(strings.NewReader("event: a\ndata: first\n\n"))
+	if _, err := d.Next(); err != nil {
+		t.Fatal(err)
+	}
+	d.Release()
+	d.Release()
+	if _, err := d.Next(); err == nil || err == io.EOF {
+		t.Fatalf("Next() after Release = %v", err)
+	}
+
+	// A decoder that reuses the released buffers starts clean.
+	got, _, err := decodeAll(t, strings.NewReader("data: second\n\n"))
+	if err != nil || !reflect.DeepEqual(got, []event{{"message", "second", ""}}) {
+		t.Fatalf("got %q, err %v", got, err)
+	}
+}
@@ -0,0 +1,190 @@
+package llmstreamer
+
+import (
+	"context"
+	"math"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// StreamStats describes the latency of a streamed response. The durations
+// are measured from Start. Deltas are the pieces of content, reasoning and
+// tool call arguments delivered to the callbacks.
+type StreamStats struct {
+	// Start is when StreamChat was called.
+	Start time.Time
+	// TimeToHeaders is the time until the response headers of the last
+	// attempt arrived.
+	TimeToHeaders time.Duration
+	// TimeToFirstToken is the time until the first delta or tool call.
+	TimeToFirstToken time.Duration
+	// TimeToLastToken is the time until the last delta.
+	TimeToLastToken time.Duration
+	// Duration is the time until the response finished.
+	Duration time.Duration
+
+	Deltas int
+	// InterTokenP50, InterTokenP90 and InterTokenP99 are percentiles of the
+	// time between consecutive deltas, accurate to about 5%.
+	InterTokenP50 time.Duration
+	InterTokenP90 time.Duration
+	InterTokenP99 time.Duration
+
+	// OutputTokens is the count the provider reported, or the number of
+	// deltas if it reported none.
+	OutputTokens int
+	// TokensPerSecond is OutputTokens over the time from the first to the
+	// last delta.
+	TokensPerSecond float64
+}
+
+const (
+	// gapBuckets per doubling give the histogram its precision; gapRange
+	// doublings from one microsecond reach beyond two minutes.
+	gapBuckets = 8
+	gapRange   = 27
+	gapCount   = gapBuckets*gapRange + 2
+)
+
+// statsRecorder measures one StreamChat call. Its histogram of gaps between
+// deltas has a fixed size, so long responses cost no extra memory.
+type statsRecorder struct {
+	start time.Time
+	// headers is the time from start until the response headers arrived,
+	// stored by the HTTP transport's goroutine.
+	headers atomic.Int64
+
+	first, last  time.Duration
+	tokenSeen    bool
+	deltas       int
+	gaps         [gapCount]uint32
+	gapTotal     int
+	outputTokens int
+}
+
+// TrackStats instruments a StreamChat call that is about to start. It returns
+// ctx, which records when the response headers arrive, and callbacks that
+// forward to cb, record the deltas, and deliver the StreamStats with the
+// FinishResult and to OnStats. If cb wants neither, both are returned
+// unchanged.
+func TrackStats(ctx context.Context, cb *StreamCallbacks) (context.Context, *StreamCallbacks) {
+	if cb == nil || cb.OnStats == nil && cb.OnFinishResult == nil {
+		return ctx, cb
+	}
+
+	r := &statsRecorder{start: time.Now()}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			r.headers.Store(int64(time.Since(r.start)))
+		},
+	})
+
+	tracked := *cb
+	tracked.OnContent = func(content string) {
+		r.delta()
+		cb.EmitContent(content)
+	}
+	tracked.OnReasoning = func(reasoning string) {
+		r.delta()
+		cb.EmitReasoning(reasoning)
+	}
+	tracked.OnToolCallStart = func(call ToolCall) {
+		r.token()
+		cb.EmitToolCallStart(call)
+	}
+	tracked.OnToolCallDelta = func(id, argumentsDelta string) {
+		r.delta()
+		cb.EmitToolCallDelta(id, argumentsDelta)
+	}
+	tracked.OnUsage = func(usage Usage) {
+		r.outputTokens = usage.OutputTokens
+		cb.EmitUsage(usage)
+	}
+	tracked.OnFinishResult = func(result FinishResult) {
+		stats := r.stats()
+		result.Stats = &stats
+		if cb.OnFinishResult != nil {
+			cb.OnFinishResult(result)
+		}
+		cb.EmitStats(stats)
+	}
+	return ctx, &tracked
+}
+
+// token records the first token.
+func (r *statsRecorder) token() time.Duration {
+	now := time.Since(r.start)
+	if !r.tokenSeen {
+		r.tokenSeen = true
+		r.first = now
+	}
+	return now
+}
+
+func (r *statsRecorder) delta() {
+	now := r.token()
+	if r.deltas > 0 {
+		r.gaps[gapBucket(now-r.last)]++
+		r.gapTotal++
+	}
+	r.deltas++
+	r.last = now
+}
+
+func (r *statsRecorder) stats() StreamStats {
+	s := StreamStats{
+		Start:            r.start,
+		TimeToHeaders:    time.Duration(r.headers.Load()),
+		TimeToFirstToken: r.first,
+		TimeToLastToken:  r.last,
+		Duration:         time.Since(r.start),
+		Deltas:           r.deltas,
+		InterTokenP50:    r.percentile(0.50),
+		InterTokenP90:    r.percentile(0.90),
+		InterTokenP99:    r.percentile(0.99),
+		OutputTokens:     r.outputTokens,
+	}
+	if s.OutputTokens == 0 {
+		s.OutputTokens = r.deltas
+	}
+	if gen := r.last - r.first; gen > 0 {
+		s.TokensPerSecond = float64(s.OutputTokens) / gen.Seconds()
+	}
+	return s
+}
+
+// percentile returns the middle of the histogram bucket holding the p-th
+// percentile gap.
+func (r *statsRecorder) percentile(p float64) time.Duration {
+	if r.gapTotal == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(r.gapTotal)))
+	seen := 0
+	for i, n := range r.gaps {
+		seen += int(n)
+		if seen >= rank {
+			return gapValue(i)
+		}
+	}
+	return gapValue(gapCount - 1)
+}
+
+// gapBucket maps a gap to its histogram bucket. Bucket 0 holds gaps under a
+// microsecond; bucket i holds gaps from 2^((i-1)/gapBuckets) microseconds up
+// to the next bucket, and the last bucket everything longer.
+func gapBucket(d time.Duration) int {
+	if d < time.Microsecond {
+		return 0
+	}
+	i := 1 + int(gapBuckets*math.Log2(float64(d)/float64(time.Microsecond)))
+	return min(i, gapCount-1)
+}
+
+func gapValue(i int) time.Duration {
+	if i == 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Microsecond) * math.Exp2((float64(i)-0.5)/gapBuckets))
+}
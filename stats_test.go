@@ -0,0 +1,97 @@
+package llmstreamer
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGapBuckets(t *testing.T) {
+	for _, d := range []time.Duration{
+		time.Microsecond, 7 * time.Microsecond, 950 * time.Microsecond,
+		12 * time.Millisecond, 333 * time.Millisecond, 45 * time.Second,
+	} {
+		got := gapValue(gapBucket(d))
+		if ratio := float64(got) / float64(d); math.Abs(ratio-1) > 0.05 {
+			t.Errorf("gap %v is reported as %v", d, got)
+		}
+	}
+	if gapBucket(0) != 0 || gapValue(0) != 0 {
+		t.Error("gaps under a microsecond are not reported as 0")
+	}
+	if gapBucket(time.Hour) != gapCount-1 {
+		t.Error("long gaps are not kept in the last bucket")
+	}
+}
+
+func TestStatsRecorder_Percentiles(t *testing.T) {
+	r := &statsRecorder{}
+	add := func(d time.Duration, n int) {
+		for i := 0; i < n; i++ {
+			r.gaps[gapBucket(d)]++
+			r.gapTotal++
+		}
+	}
+	add(10*time.Millisecond, 50)
+	add(20*time.Millisecond, 40)
+	add(50*time.Millisecond, 9)
+	add(time.Second, 1)
+
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.50, 10 * time.Millisecond},
+		{0.90, 20 * time.Millisecond},
+		{0.99, 50 * time.Millisecond},
+		{1, time.Second},
+	} {
+		got := r.percentile(tt.p)
+		if ratio := float64(got) / float64(tt.want); math.Abs(ratio-1) > 0.05 {
+			t.Errorf("percentile(%v) = %v, want about %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestTrackStats(t *testing.T) {
+	var result FinishResult
+	var stats []StreamStats
+	var finished bool
+	cb := &StreamCallbacks{
+		OnFinishResult: func(r FinishResult) { result = r },
+		OnFinish:       func(string) { finished = true },
+		OnStats:        func(s StreamStats) { stats = append(stats, s) },
+	}
+
+	_, tracked := TrackStats(context.Background(), cb)
+	time.Sleep(20 * time.Millisecond)
+	tracked.EmitReasoning("hmm")
+	for i := 0; i < 4; i++ {
+		time.Sleep(5 * time.Millisecond)
+		tracked.EmitContent("tok")
+	}
+	tracked.EmitUsage(Usage{OutputTokens: 10})
+	tracked.EmitFinishResult(FinishResult{Message: "toktoktoktok"})
+
+	if len(stats) != 1 || result.Stats == nil || *result.Stats != stats[0] || !finished {
+		t.Fatalf("stats %+v, result %+v, finished %v", stats, result, finished)
+	}
+	s := stats[0]
+	if s.TimeToFirstToken < 20*time.Millisecond || s.TimeToLastToken < s.TimeToFirstToken+20*time.Millisecond || s.Duration < s.TimeToLastToken {
+		t.Fatalf("unexpected timings: %+v", s)
+	}
+	if s.Deltas != 5 || s.OutputTokens != 10 || s.InterTokenP50 < 4*time.Millisecond {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if want := 10 / (s.TimeToLastToken - s.TimeToFirstToken).Seconds(); s.TokensPerSecond != want {
+		t.Fatalf("TokensPerSecond = %v, want %v", s.TokensPerSecond, want)
+	}
+}
+
+func TestTrackStats_Unobserved(t *testing.T) {
+	cb := &StreamCallbacks{OnContent: func(string) {}}
+	if _, got := TrackStats(context.Background(), cb); got != cb {
+		t.Fatal("callbacks without OnStats or OnFinishResult were wrapped")
+	}
+}
@@ -0,0 +1,187 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"sync"
+)
+
+type EventType string
+
+const (
+	EventContent       EventType = "content"
+	EventReasoning     EventType = "reasoning"
+	EventToolCallStart EventType = "tool_call_start"
+	EventToolCallDelta EventType = "tool_call_delta"
+	EventToolCall      EventType = "tool_call"
+	EventUsage         EventType = "usage"
+	EventFinish        EventType = "finish"
+	EventError         EventType = "error"
+)
+
+// Event is one item of a Stream. Each event corresponds to a StreamCallbacks
+// callback and only the fields for its Type are set:
+//
+//   - EventContent and EventReasoning: Text.
+//   - EventToolCallStart and EventToolCall: ToolCall.
+//   - EventToolCallDelta: Text holds the arguments fragment and ToolCall.ID
+//     the call it belongs to.
+//   - EventUsage: Usage.
+//   - EventFinish: Result.
+//   - EventError: Err.
+type Event struct {
+	Type     EventType
+	Text     string
+	ToolCall ToolCall
+	Usage    Usage
+	Result   FinishResult
+	Err      error
+}
+
+// streamBuffer is how many events a Stream holds before the provider waits for
+// the consumer.
+const streamBuffer = 64
+
+// Stream is a response being streamed in the background. Its events are read
+// once, either from Events, with All, or with Text. Close must be called if
+// the events are not read to the end.
+type Stream struct {
+	events chan Event
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewStream starts streaming a response from s and waits until the first
+// event arrives. If the request fails before anything is delivered, for
+// example because of an invalid API key, the error is returned instead of a
+// Stream.
+func NewStream(ctx context.Context, s Streamer, messages []Message, opts ...Option) (*Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	st := &Stream{
+		events: make(chan Event, streamBuffer),
+		cancel: cancel,
+	}
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	// Errors reported before the first event are held back so that NewStream
+	// can return them.
+	var early []error
+	isStarted := false
+
+	send := func(ev Event) {
+		if ev.Type == EventError {
+			st.mu.Lock()
+			st.errs = append(st.errs, ev.Err)
+			st.mu.Unlock()
+
+			if !isStarted {
+				early = append(early, ev.Err)
+				return
+			}
+		} else if !isStarted {
+			isStarted = true
+			close(started)
+			for _, err := range early {
+				st.push(ctx, Event{Type: EventError, Err: err})
+			}
+		}
+		st.push(ctx, ev)
+	}
+
+	cb := &StreamCallbacks{
+		OnContent:   func(content string) { send(Event{Type: EventContent, Text: content}) },
+		OnReasoning: func(reasoning string) { send(Event{Type: EventReasoning, Text: reasoning}) },
+		OnToolCallStart: func(call ToolCall) {
+			send(Event{Type: EventToolCallStart, ToolCall: call})
+		},
+		OnToolCallDelta: func(id string, delta string) {
+			send(Event{Type: EventToolCallDelta, Text: delta, ToolCall: ToolCall{ID: id}})
+		},
+		OnToolCall:     func(call ToolCall) { send(Event{Type: EventToolCall, ToolCall: call}) },
+		OnUsage:        func(usage Usage) { send(Event{Type: EventUsage, Usage: usage}) },
+		OnFinishResult: func(result FinishResult) { send(Event{Type: EventFinish, Result: result}) },
+		OnError:        func(err error) { send(Event{Type: EventError, Err: err}) },
+	}
+
+	go func() {
+		defer close(st.events)
+		defer close(done)
+		defer cancel()
+		s.StreamChat(ctx, messages, cb, opts...)
+	}()
+
+	select {
+	case <-started:
+		return st, nil
+	case <-done:
+		if !isStarted {
+			if err := errors.Join(early...); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("llmstreamer: stream ended without any events")
+		}
+		return st, nil
+	}
+}
+
+// push delivers ev unless the stream was closed.
+func (st *Stream) push(ctx context.Context, ev Event) {
+	select {
+	case st.events <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// Events returns the channel the events are delivered on. It is closed when
+// the response ends, after which Err reports whether it failed.
+func (st *Stream) Events() <-chan Event {
+	return st.events
+}
+
+// All returns an iterator over the events. Errors are yielded with an
+// EventError event; other events are yielded with a nil error. Stopping the
+// iteration early closes the stream.
+func (st *Stream) All() iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		for ev := range st.events {
+			if !yield(ev, ev.Err) {
+				st.Close()
+				return
+			}
+		}
+	}
+}
+
+// Text reads the rest of the stream and returns the content it delivered,
+// together with Err.
+func (st *Stream) Text() (string, error) {
+	var b strings.Builder
+	for ev := range st.events {
+		if ev.Type == EventContent {
+			b.WriteString(ev.Text)
+		}
+	}
+	return b.String(), st.Err()
+}
+
+// Err returns the errors reported so far, joined. It is complete once the
+// events have been read to the end.
+func (st *Stream) Err() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return errors.Join(st.errs...)
+}
+
+// Close cancels the request and discards the events that have not been read.
+// It is safe to call more than once and after the stream has ended.
+func (st *Stream) Close() {
+	st.cancel()
+	for range st.events {
+	}
+}
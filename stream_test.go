@@ -0,0 +1,171 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// funcStreamer runs fn for every StreamChat call.
+type funcStreamer func(ctx context.Context, cb *StreamCallbacks)
+
+func (f funcStreamer) StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) {
+	f(ctx, cb)
+}
+
+func TestStream_Text(t *testing.T) {
+	s := &chunkStreamer{chunks: []string{"Hello", ", ", "world"}}
+
+	st, err := NewStream(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := st.Text()
+	if err != nil || text != "Hello, world" {
+		t.Fatalf("Text() = %q, %v", text, err)
+	}
+}
+
+func TestStream_Events(t *testing.T) {
+	call := ToolCall{ID: "c1", Name: "add", Arguments: `{"A":1}`}
+	s := funcStreamer(func(ctx context.Context, cb *StreamCallbacks) {
+		cb.EmitReasoning("thinking")
+		cb.EmitContent("Let me add.")
+		cb.EmitToolCallStart(ToolCall{ID: call.ID, Name: call.Name})
+		cb.EmitToolCallDelta(call.ID, call.Arguments)
+		cb.EmitToolCall(call)
+		cb.EmitUsage(Usage{InputTokens: 3, OutputTokens: 5})
+		cb.EmitFinishResult(FinishResult{Message: "Let me add.", StopReason: StopReasonToolUse})
+	})
+
+	st, err := NewStream(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Event
+	for ev := range st.Events() {
+		got = append(got, ev)
+	}
+
+	want := []Event{
+		{Type: EventReasoning, Text: "thinking"},
+		{Type: EventContent, Text: "Let me add."},
+		{Type: EventToolCallStart, ToolCall: ToolCall{ID: "c1", Name: "add"}},
+		{Type: EventToolCallDelta, Text: `{"A":1}`, ToolCall: ToolCall{ID: "c1"}},
+		{Type: EventToolCall, ToolCall: call},
+		{Type: EventUsage, Usage: Usage{InputTokens: 3, OutputTokens: 5}},
+		{Type: EventFinish, Result: FinishResult{Message: "Let me add.", StopReason: StopReasonToolUse}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events:\n got %+v\nwant %+v", got, want)
+	}
+	if err := st.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStream_ReleasesContextAtEnd(t *testing.T) {
+	var streamCtx context.Context
+	s := funcStreamer(func(ctx context.Context, cb *StreamCallbacks) {
+		streamCtx = ctx
+		cb.EmitContent("done")
+	})
+
+	st, err := NewStream(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Text(); err != nil {
+		t.Fatal(err)
+	}
+	if streamCtx.Err() == nil {
+		t.Fatal("the request context was not released after the stream ended")
+	}
+}
+
+func TestStream_ErrorBeforeFirstEvent(t *testing.T) {
+	errAuth := errors.New("invalid apiKey")
+	s := &chunkStreamer{err: errAuth}
+
+	st, err := NewStream(context.Background(), s, nil)
+	if st != nil || !errors.Is(err, errAuth) {
+		t.Fatalf("NewStream() = %v, %v", st, err)
+	}
+}
+
+func TestStream_All(t *testing.T) {
+	errParse := errors.New("failed to parse JSON")
+	errRead := errors.New("read failed")
+	s := funcStreamer(func(ctx context.Context, cb *StreamCallbacks) {
+		// An error reported before the first event is still delivered once
+		// the stream has started.
+		cb.EmitError(errParse)
+		cb.EmitContent("Hel")
+		cb.EmitError(errRead)
+	})
+
+	st, err := NewStream(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []EventType
+	var errs []error
+	for ev, err := range st.All() {
+		types = append(types, ev.Type)
+		errs = append(errs, err)
+	}
+
+	if !reflect.DeepEqual(types, []EventType{EventError, EventContent, EventError}) {
+		t.Fatalf("unexpected events: %v", types)
+	}
+	if errs[0] != errParse || errs[1] != nil || errs[2] != errRead {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if err := st.Err(); !errors.Is(err, errParse) || !errors.Is(err, errRead) {
+		t.Fatalf("Err() = %v", err)
+	}
+}
+
+func TestStream_BreakCancelsRequest(t *testing.T) {
+	stopped := make(chan error, 1)
+	s := funcStreamer(func(ctx context.Context, cb *StreamCallbacks) {
+		for {
+			select {
+			case <-ctx.Done():
+				stopped <- ctx.Err()
+				return
+			default:
+				cb.EmitContent("tick")
+			}
+		}
+	})
+
+	st, err := NewStream(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for range st.All() {
+		if n++; n == 3 {
+			break
+		}
+	}
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request was not canceled")
+	}
+
+	// Closing again after the stream has ended is a no-op.
+	st.Close()
+}
@@ -1,7 +1,97 @@
 package llmstreamer
 
+import "context"
+
+type Streamer interface {
+	StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option)
+}
+
 type StreamCallbacks struct {
 	OnContent func(content string)
 	OnFinish  func(finalMessage string)
 	OnError   func(err error)
+
+	// OnReasoning receives the model's reasoning as it streams, for
+	// providers that send it separately from the answer.
+	OnReasoning func(reasoning string)
+
+	OnToolCallStart func(call ToolCall)
+	OnToolCallDelta func(id string, argumentsDelta string)
+	OnToolCall      func(call ToolCall)
+
+	OnUsage func(usage Usage)
+
+	// OnFinishResult is called with the details of the response just before
+	// OnFinish.
+	OnFinishResult func(result FinishResult)
+
+	// OnStats is called with the latency statistics of the response after
+	// OnFinishResult.
+	OnStats func(stats StreamStats)
+}
+
+// The Emit methods invoke the matching callback if it is set. They are safe to
+// call on a nil *StreamCallbacks.
+
+func (cb *StreamCallbacks) EmitContent(content string) {
+	if cb != nil && cb.OnContent != nil {
+		cb.OnContent(content)
+	}
+}
+
+func (cb *StreamCallbacks) EmitReasoning(reasoning string) {
+	if cb != nil && cb.OnReasoning != nil {
+		cb.OnReasoning(reasoning)
+	}
+}
+
+func (cb *StreamCallbacks) EmitFinish(finalMessage string) {
+	if cb != nil && cb.OnFinish != nil {
+		cb.OnFinish(finalMessage)
+	}
+}
+
+// EmitFinishResult calls OnFinishResult followed by OnFinish with the final
+// message.
+func (cb *StreamCallbacks) EmitFinishResult(result FinishResult) {
+	if cb != nil && cb.OnFinishResult != nil {
+		cb.OnFinishResult(result)
+	}
+	cb.EmitFinish(result.Message)
+}
+
+func (cb *StreamCallbacks) EmitError(err error) {
+	if cb != nil && cb.OnError != nil {
+		cb.OnError(err)
+	}
+}
+
+func (cb *StreamCallbacks) EmitToolCallStart(call ToolCall) {
+	if cb != nil && cb.OnToolCallStart != nil {
+		cb.OnToolCallStart(call)
+	}
+}
+
+func (cb *StreamCallbacks) EmitToolCallDelta(id string, argumentsDelta string) {
+	if cb != nil && cb.OnToolCallDelta != nil {
+		cb.OnToolCallDelta(id, argumentsDelta)
+	}
+}
+
+func (cb *StreamCallbacks) EmitToolCall(call ToolCall) {
+	if cb != nil && cb.OnToolCall != nil {
+		cb.OnToolCall(call)
+	}
+}
+
+func (cb *StreamCallbacks) EmitUsage(usage Usage) {
+	if cb != nil && cb.OnUsage != nil {
+		cb.OnUsage(usage)
+	}
+}
+
+func (cb *StreamCallbacks) EmitStats(stats StreamStats) {
+	if cb != nil && cb.OnStats != nil {
+		cb.OnStats(stats)
+	}
 }
@@ -0,0 +1,89 @@
+package llmstreamer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+
+	"github.com/alparslanyilmaaz/llmstreamer/jsonschema"
+	"github.com/alparslanyilmaaz/llmstreamer/partialjson"
+)
+
+var ErrNoJSON = errors.New("llmstreamer: response did not contain a JSON value")
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// StreamInto asks the model for a JSON answer matching the schema of T and
+// decodes it while it streams. onPartial, if non-nil, receives a progressively
+// filled T each time a chunk adds to the value; fields that have not arrived
+// yet hold their zero value. The final value is validated against the schema.
+//
+// Providers enforce the schema natively where they can (OpenAI
+// response_format, a forced tool call on Anthropic).
+func StreamInto[T any](ctx context.Context, s Streamer, messages []Message, onPartial func(partial T), opts ...Option) (T, error) {
+	var zero T
+
+	rf, err := NewResponseFormat[T](responseName[T]())
+	if err != nil {
+		return zero, err
+	}
+
+	var (
+		parser   = partialjson.NewParser()
+		last     []byte
+		finished bool
+		lastErr  error
+	)
+
+	cb := &StreamCallbacks{
+		OnContent: func(content string) {
+			if _, err := parser.WriteString(content); err != nil || onPartial == nil {
+				return
+			}
+
+			doc := parser.Complete()
+			if doc == nil || bytes.Equal(doc, last) {
+				return
+			}
+			last = doc
+
+			var partial T
+			if err := json.Unmarshal(doc, &partial); err == nil {
+				onPartial(partial)
+			}
+		},
+		OnFinish: func(string) { finished = true },
+		OnError:  func(err error) { lastErr = err },
+	}
+
+	// Copy opts so the response format is never appended into spare
+	// capacity of the caller's slice.
+	o := make([]Option, 0, len(opts)+1)
+	o = append(append(o, opts...), WithResponseFormat(rf))
+	s.StreamChat(ctx, messages, cb, o...)
+
+	if !finished {
+		if lastErr == nil {
+			lastErr = errors.New("llmstreamer: stream ended without finishing")
+		}
+		return zero, lastErr
+	}
+	if err := parser.Err(); err != nil {
+		return zero, err
+	}
+	if !parser.Done() {
+		return zero, ErrNoJSON
+	}
+	return jsonschema.Decode[T](parser.Bytes())
+}
+
+func responseName[T any]() string {
+	name := invalidNameChars.ReplaceAllString(reflect.TypeOf((*T)(nil)).Elem().Name(), "_")
+	if name == "" {
+		return "response"
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+package llmstreamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type chunkStreamer struct {
+	chunks []string
+	err    error
+	opts   Options
+}
+
+func (s *chunkStreamer) StreamChat(ctx context.Context, messages []Message, cb *StreamCallbacks, opts ...Option) {
+	s.opts = NewOptions(opts...)
+	for _, c := range s.chunks {
+		cb.EmitContent(c)
+	}
+	if s.err != nil {
+		cb.EmitError(s.err)
+		return
+	}
+	cb.EmitFinish(strings.Join(s.chunks, ""))
+}
+
+type recipe struct {
+	Title       string   `json:"title" jsonschema:"required"`
+	Servings    int      `json:"servings" jsonschema:"minimum=1"`
+	Ingredients []string `json:"ingredients"`
+}
+
+func TestStreamInto(t *testing.T) {
+	s := &chunkStreamer{chunks: []string{
+		"```json\n{\"title\": \"Pan",
+		"cakes\", \"servings\"",
+		": 4, \"ingredients\": [\"flour\", \"eg",
+		"gs\"]}",
+		"\n```",
+	}}
+
+	var partials []recipe
+	got, err := StreamInto(context.Background(), s, nil, func(p recipe) {
+		partials = append(partials, p)
+	})
+	if err != nil {
+		t.Fatalf("StreamInto returned error: %v", err)
+	}
+
+	if got.Title != "Pancakes" || got.Servings != 4 || len(got.Ingredients) != 2 || got.Ingredients[1] != "eggs" {
+		t.Fatalf("unexpected final value: %+v", got)
+	}
+
+	if len(partials) != 4 {
+		t.Fatalf("expected 4 partial values, got %d: %+v", len(partials), partials)
+	}
+	if partials[0].Title != "Pan" || partials[1].Title != "Pancakes" || partials[1].Servings != 0 {
+		t.Fatalf("unexpected early partials: %+v", partials[:2])
+	}
+	if partials[2].Servings != 4 || len(partials[2].Ingredients) != 2 || partials[2].Ingredients[1] != "eg" {
+		t.Fatalf("unexpected partial: %+v", partials[2])
+	}
+
+	rf := s.opts.ResponseFormat
+	if rf == nil || rf.Name != "recipe" || !json.Valid(rf.Schema) {
+		t.Fatalf("expected response format to be requested, got %+v", rf)
+	}
+}
+
+func TestStreamInto_ValidationError(t *testing.T) {
+	s := &chunkStreamer{chunks: []string{`{"servings": 0}`}}
+
+	if _, err := StreamInto[recipe](context.Background(), s, nil, nil); err == nil {
+		t.Fatalf("expected validation error")
+	}
+}
+
+func TestStreamInto_NoJSON(t *testing.T) {
+	s := &chunkStreamer{chunks: []string{`I can't do that.`}}
+
+	if _, err := StreamInto[recipe](context.Background(), s, nil, nil); !errors.Is(err, ErrNoJSON) {
+		t.Fatalf("expected ErrNoJSON, got %v", err)
+	}
+
+	s = &chunkStreamer{chunks: []string{`{"title": "Pan`}}
+	if _, err := StreamInto[recipe](context.Background(), s, nil, nil); !errors.Is(err, ErrNoJSON) {
+		t.Fatalf("expected ErrNoJSON for truncated answer, got %v", err)
+	}
+}
+
+func TestStreamInto_StreamError(t *testing.T) {
+	want := errors.New("boom")
+	s := &chunkStreamer{chunks: []string{`{"title"`}, err: want}
+
+	if _, err := StreamInto[recipe](context.Background(), s, nil, nil); !errors.Is(err, want) {
+		t.Fatalf("expected stream error, got %v", err)
+	}
+}
+
+func TestStreamInto_DoesNotWriteCallerOptions(t *testing.T) {
+	s := &chunkStreamer{chunks: []string{`{"title": "Pancakes", "servings": 4}`}}
+
+	sentinel := WithTemperature(0.5)
+	opts := make([]Option, 1, 2)
+	opts[0] = WithMaxTokens(100)
+	opts = append(opts[:1], sentinel)[:1]
+
+	if _, err := StreamInto[recipe](context.Background(), s, nil, nil, opts...); err != nil {
+		t.Fatalf("StreamInto returned error: %v", err)
+	}
+	if s.opts.MaxTokens != 100 || s.opts.ResponseFormat == nil {
+		t.Fatalf("unexpected options: %+v", s.opts)
+	}
+
+	var got Options
+	opts[:2][1](&got)
+	if got.Temperature == nil || *got.Temperature != 0.5 || got.ResponseFormat != nil {
+		t.Fatalf("caller's spare capacity was overwritten: %+v", got)
+	}
+}
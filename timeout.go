@@ -0,0 +1,152 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFirstTokenTimeout = errors.New("llmstreamer: no token before the first-token timeout")
+	ErrStreamStalled     = errors.New("llmstreamer: stream stalled")
+	// ErrStreamTimeout also matches context.DeadlineExceeded, so a request
+	// that ran out of time is not retried.
+	ErrStreamTimeout = fmt.Errorf("llmstreamer: stream timed out: %w", context.DeadlineExceeded)
+)
+
+// Timeouts bound a streaming request. A limit that runs out aborts the
+// request, closing its connection, and the attempt fails with a
+// *TimeoutError. Zero fields set no limit, and a nil *Timeouts sets none.
+//
+// The limits apply to each attempt of a retried request; bound the request as
+// a whole with its context.
+type Timeouts struct {
+	// FirstToken bounds the time from sending the request to the first
+	// content, reasoning or tool call.
+	FirstToken time.Duration
+	// Idle bounds the time without receiving any bytes of the response,
+	// starting when the request is sent.
+	Idle time.Duration
+	// Total bounds the whole attempt.
+	Total time.Duration
+}
+
+// TimeoutError reports a request aborted by Timeouts. Err is
+// ErrFirstTokenTimeout, ErrStreamStalled or ErrStreamTimeout.
+type TimeoutError struct {
+	Err     error
+	Timeout time.Duration
+	// Partial is the content delivered before the timeout.
+	Partial string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s after %v", strings.TrimSuffix(e.Err.Error(), ": "+context.DeadlineExceeded.Error()), e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+type watchdogKey struct{}
+
+// watchdog holds the idle timer of an attempt for WatchBody.
+type watchdog struct {
+	idle  time.Duration
+	timer *time.Timer
+}
+
+// Do runs attempt under the timeouts. attempt must send its request with the
+// context it receives and wrap the response body with WatchBody, and receives
+// callbacks that forward to cb.
+func (t *Timeouts) Do(ctx context.Context, cb *StreamCallbacks, attempt func(ctx context.Context, cb *StreamCallbacks) error) error {
+	if t == nil || t.FirstToken <= 0 && t.Idle <= 0 && t.Total <= 0 {
+		return attempt(ctx, cb)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	after := func(d time.Duration, err error) *time.Timer {
+		if d <= 0 {
+			return nil
+		}
+		return time.AfterFunc(d, func() {
+			cancel(&TimeoutError{Err: err, Timeout: d})
+		})
+	}
+	stop := func(timer *time.Timer) {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	first := after(t.FirstToken, ErrFirstTokenTimeout)
+	total := after(t.Total, ErrStreamTimeout)
+	w := &watchdog{idle: t.Idle, timer: after(t.Idle, ErrStreamStalled)}
+	defer stop(first)
+	defer stop(total)
+	defer stop(w.timer)
+
+	var partial strings.Builder
+	gotToken := func() {
+		stop(first)
+	}
+
+	tracked := &StreamCallbacks{}
+	if cb != nil {
+		*tracked = *cb
+	}
+	tracked.OnContent = func(content string) {
+		gotToken()
+		partial.WriteString(content)
+		cb.EmitContent(content)
+	}
+	tracked.OnReasoning = func(reasoning string) {
+		gotToken()
+		cb.EmitReasoning(reasoning)
+	}
+	tracked.OnToolCallStart = func(call ToolCall) {
+		gotToken()
+		cb.EmitToolCallStart(call)
+	}
+
+	err := attempt(context.WithValue(ctx, watchdogKey{}, w), tracked)
+	if err == nil {
+		return nil
+	}
+
+	var timeoutErr *TimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		timeoutErr.Partial = partial.String()
+		return timeoutErr
+	}
+	return err
+}
+
+// WatchBody returns body wrapped so that every read restarts the idle timeout
+// of the Timeouts.Do call that ctx comes from. Without an idle timeout it
+// returns body unchanged.
+func WatchBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	w, _ := ctx.Value(watchdogKey{}).(*watchdog)
+	if w == nil || w.timer == nil {
+		return body
+	}
+	return &watchedBody{ReadCloser: body, w: w}
+}
+
+type watchedBody struct {
+	io.ReadCloser
+	w *watchdog
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.timer.Reset(b.w.idle)
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package llmstreamer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// tickingAttempt delivers content, then reads a body that sends n bytes, one
+// every interval, and then nothing, the way a stalled connection behaves.
+func tickingAttempt(content string, n int, interval time.Duration) func(ctx context.Context, cb *StreamCallbacks) error {
+	return func(ctx context.Context, cb *StreamCallbacks) error {
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < n; i++ {
+				time.Sleep(interval)
+				pw.Write([]byte("x"))
+			}
+		}()
+		// The HTTP transport aborts the body read when the request is
+		// canceled.
+		stop := context.AfterFunc(ctx, func() { pw.CloseWithError(ctx.Err()) })
+		defer stop()
+
+		if content != "" {
+			cb.EmitContent(content)
+		}
+		_, err := io.Copy(io.Discard, WatchBody(ctx, pr))
+		return err
+	}
+}
+
+func TestTimeouts_FirstToken(t *testing.T) {
+	tm := &Timeouts{FirstToken: 20 * time.Millisecond, Idle: time.Second}
+	err := tm.Do(context.Background(), nil, tickingAttempt("", 0, 0))
+
+	var timeoutErr *TimeoutError
+	if !errors.Is(err, ErrFirstTokenTimeout) || !errors.As(err, &timeoutErr) || timeoutErr.Timeout != tm.FirstToken {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsRetryable(err) {
+		t.Fatal("a first-token timeout should be retryable")
+	}
+}
+
+func TestTimeouts_Stalled(t *testing.T) {
+	var content string
+	cb := &StreamCallbacks{OnContent: func(c string) { content += c }}
+
+	// The bytes keep the stream alive well past the idle timeout, and the
+	// content stops the first-token timer.
+	tm := &Timeouts{FirstToken: 20 * time.Millisecond, Idle: 40 * time.Millisecond}
+	start := time.Now()
+	err := tm.Do(context.Background(), cb, tickingAttempt("Hel", 5, 20*time.Millisecond))
+
+	var timeoutErr *TimeoutError
+	if !errors.Is(err, ErrStreamStalled) || !errors.As(err, &timeoutErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeoutErr.Partial != "Hel" || content != "Hel" {
+		t.Fatalf("partial %q, content %q", timeoutErr.Partial, content)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("stalled after %v, before the body stopped", elapsed)
+	}
+}
+
+func TestTimeouts_Total(t *testing.T) {
+	tm := &Timeouts{Idle: time.Second, Total: 30 * time.Millisecond}
+	err := tm.Do(context.Background(), nil, tickingAttempt("Hel", 1000, time.Millisecond))
+
+	if !errors.Is(err, ErrStreamTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if IsRetryable(err) {
+		t.Fatal("a total timeout should not be retryable")
+	}
+	if err.Error() != "llmstreamer: stream timed out after 30ms" {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+}
+
+func TestTimeouts_Finished(t *testing.T) {
+	errBoom := errors.New("boom")
+	tm := &Timeouts{FirstToken: time.Second, Idle: time.Second, Total: time.Second}
+	for _, want := range []error{nil, errBoom} {
+		err := tm.Do(context.Background(), nil, func(ctx context.Context, cb *StreamCallbacks) error {
+			return want
+		})
+		if err != want {
+			t.Fatalf("Do() = %v, want %v", err, want)
+		}
+	}
+
+	var none *Timeouts
+	if err := none.Do(context.Background(), nil, func(context.Context, *StreamCallbacks) error { return errBoom }); err != errBoom {
+		t.Fatalf("nil Timeouts: %v", err)
+	}
+}
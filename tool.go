@@ -0,0 +1,80 @@
+package llmstreamer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alparslanyilmaaz/llmstreamer/jsonschema"
+)
+
+// Tool describes a function the model may call. Parameters is a JSON schema
+// object describing the arguments.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// NewTool builds a tool whose parameters are the JSON schema of T. See
+// jsonschema.Generate for the supported struct tags.
+func NewTool[T any](name, description string) (Tool, error) {
+	schema, err := schemaJSON[T]()
+	if err != nil {
+		return Tool{}, fmt.Errorf("llmstreamer: tool %q: %w", name, err)
+	}
+	return Tool{Name: name, Description: description, Parameters: schema}, nil
+}
+
+func schemaJSON[T any]() (json.RawMessage, error) {
+	s, err := jsonschema.For[T]()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// ToolCall is a request from the model to run a tool. Arguments holds the raw
+// JSON arguments exactly as produced by the model.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type ToolChoiceMode string
+
+const (
+	ToolChoiceAuto     ToolChoiceMode = "auto"
+	ToolChoiceNone     ToolChoiceMode = "none"
+	ToolChoiceRequired ToolChoiceMode = "required"
+	ToolChoiceTool     ToolChoiceMode = "tool"
+)
+
+// ToolChoice controls whether and which tools the model calls. Name is only
+// used with ToolChoiceTool.
+type ToolChoice struct {
+	Mode ToolChoiceMode
+	Name string
+}
+
+// ToolResult builds the message that returns a tool's output to the model.
+func ToolResult(callID string, content string) Message {
+	return Message{Role: RoleTool, ToolCallID: callID, Content: content}
+}
+
+// ResponseFormat asks the model to answer with JSON matching Schema.
+type ResponseFormat struct {
+	Name        string
+	Description string
+	Schema      json.RawMessage
+	Strict      bool
+}
+
+// NewResponseFormat builds a response format from the JSON schema of T.
+func NewResponseFormat[T any](name string) (ResponseFormat, error) {
+	schema, err := schemaJSON[T]()
+	if err != nil {
+		return ResponseFormat{}, fmt.Errorf("llmstreamer: response format %q: %w", name, err)
+	}
+	return ResponseFormat{Name: name, Schema: schema}, nil
+}
@@ -0,0 +1,94 @@
+package llmstreamer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToolResult(t *testing.T) {
+	m := ToolResult("call_1", "42")
+	if m.Role != RoleTool || m.ToolCallID != "call_1" || m.Content != "42" {
+		t.Fatalf("unexpected tool result message: %+v", m)
+	}
+}
+
+func TestOptionsValidate_Tools(t *testing.T) {
+	weather := Tool{Name: "weather", Parameters: json.RawMessage(`{"type":"object"}`)}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"valid", []Option{WithTools(weather), WithToolChoice(ToolChoiceAuto)}, false},
+		{"forced known tool", []Option{WithTools(weather), WithForcedTool("weather")}, false},
+		{"forced unknown tool", []Option{WithTools(weather), WithForcedTool("search")}, true},
+		{"empty name", []Option{WithTools(Tool{})}, true},
+		{"duplicate", []Option{WithTools(weather, weather)}, true},
+		{"invalid schema", []Option{WithTools(Tool{Name: "x", Parameters: json.RawMessage(`{`)})}, true},
+		{"unknown mode", []Option{WithTools(weather), WithToolChoice("sometimes")}, true},
+	}
+
+	for _, tt := range tests {
+		err := NewOptions(tt.opts...).Validate()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: expected error=%v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestStreamCallbacks_EmitNilSafe(t *testing.T) {
+	var cb *StreamCallbacks
+	cb.EmitContent("x")
+	cb.EmitFinish("x")
+	cb.EmitError(nil)
+	cb.EmitToolCallStart(ToolCall{})
+	cb.EmitToolCallDelta("id", "{}")
+	cb.EmitToolCall(ToolCall{})
+
+	(&StreamCallbacks{}).EmitContent("x")
+}
+
+type weatherArgs struct {
+	City string `json:"city" description:"City name" jsonschema:"required"`
+	Days int    `json:"days" jsonschema:"minimum=1"`
+}
+
+func TestNewTool(t *testing.T) {
+	tool, err := NewTool[weatherArgs]("get_weather", "Get the weather")
+	if err != nil {
+		t.Fatalf("NewTool returned error: %v", err)
+	}
+	if tool.Name != "get_weather" || tool.Description != "Get the weather" {
+		t.Fatalf("unexpected tool: %+v", tool)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(tool.Parameters, &schema); err != nil {
+		t.Fatalf("parameters are not valid JSON: %v", err)
+	}
+	if schema["type"] != "object" || schema["properties"].(map[string]interface{})["city"] == nil {
+		t.Fatalf("unexpected schema: %s", tool.Parameters)
+	}
+
+	if _, err := NewTool[chan int]("bad", ""); err == nil {
+		t.Fatalf("expected error for unsupported type")
+	}
+}
+
+func TestNewResponseFormat(t *testing.T) {
+	rf, err := NewResponseFormat[weatherArgs]("weather")
+	if err != nil {
+		t.Fatalf("NewResponseFormat returned error: %v", err)
+	}
+	if rf.Name != "weather" || !json.Valid(rf.Schema) {
+		t.Fatalf("unexpected response format: %+v", rf)
+	}
+
+	if err := NewOptions(WithResponseFormat(rf)).Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if err := NewOptions(WithResponseFormat(ResponseFormat{Schema: rf.Schema})).Validate(); err == nil {
+		t.Fatalf("expected error for unnamed response format")
+	}
+}
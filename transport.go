@@ -0,0 +1,226 @@
+package llmstreamer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// TransportConfig tunes the connection pool of a Transport. Zero fields take
+// the defaults noted, which suit a service sending many concurrent requests
+// to one provider host.
+type TransportConfig struct {
+	// MaxIdleConns bounds the idle connections kept across all hosts.
+	// Default 100.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost bounds the idle connections kept per host. The
+	// net/http default of 2 makes busy services dial constantly. Default 100.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost bounds the connections per host, including those in
+	// use. Default 0, no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes connections that stay idle this long.
+	// Default 90s.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout bounds connecting to the host. Default 30s.
+	DialTimeout time.Duration
+	// KeepAlive is the TCP keep-alive interval. Default 30s.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake. Default 10s.
+	TLSHandshakeTimeout time.Duration
+	// TLSConfig, if set, configures the TLS client.
+	TLSConfig *tls.Config
+
+	// DisableHTTP2 keeps connections on HTTP/1.1. By default HTTP/2 is used
+	// where the server supports it, multiplexing requests on one connection.
+	DisableHTTP2 bool
+}
+
+// TransportStats counts the requests a Transport has sent and how their
+// connections were obtained.
+type TransportStats struct {
+	Requests int64
+	// NewConns is the number of requests that had to open a connection.
+	NewConns int64
+	// ReusedConns is the number of requests that used a pooled connection.
+	ReusedConns int64
+	// TLSHandshakes is the number of TLS handshakes completed.
+	TLSHandshakes int64
+}
+
+// ReuseRatio returns the fraction of connections that were reused, or 0
+// before any request.
+func (s TransportStats) ReuseRatio() float64 {
+	n := s.NewConns + s.ReusedConns
+	if n == 0 {
+		return 0
+	}
+	return float64(s.ReusedConns) / float64(n)
+}
+
+// Transport is an http.RoundTripper with a connection pool tuned for
+// streaming requests. It records how connections are reused, see Stats, and
+// can open connections ahead of traffic, see Warm. Share one Transport
+// between streamers that talk to the same host to share their pool.
+//
+// A nil *Transport sends requests with http.DefaultClient and reports no
+// stats.
+type Transport struct {
+	base   *http.Transport
+	client *http.Client
+
+	requests      atomic.Int64
+	newConns      atomic.Int64
+	reusedConns   atomic.Int64
+	tlsHandshakes atomic.Int64
+}
+
+func NewTransport(cfg TransportConfig) *Transport {
+	dialer := &net.Dialer{
+		Timeout:   orDuration(cfg.DialTimeout, 30*time.Second),
+		KeepAlive: orDuration(cfg.KeepAlive, 30*time.Second),
+	}
+
+	base := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     cfg.TLSConfig,
+		ForceAttemptHTTP2:   !cfg.DisableHTTP2,
+		MaxIdleConns:        orInt(cfg.MaxIdleConns, 100),
+		MaxIdleConnsPerHost: orInt(cfg.MaxIdleConnsPerHost, 100),
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     orDuration(cfg.IdleConnTimeout, 90*time.Second),
+		TLSHandshakeTimeout: orDuration(cfg.TLSHandshakeTimeout, 10*time.Second),
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns off HTTP/2.
+		base.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	t := &Transport{base: base}
+	// Streams can run for minutes, so the client sets no overall timeout;
+	// use the request context instead.
+	t.client = &http.Client{Transport: t}
+	return t
+}
+
+func orInt(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func orDuration(v, def time.Duration) time.Duration {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Client returns an *http.Client that sends its requests through t.
+func (t *Transport) Client() *http.Client {
+	if t == nil {
+		return http.DefaultClient
+	}
+	return t.client
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	t.requests.Add(1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.reusedConns.Add(1)
+			} else {
+				t.newConns.Add(1)
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.tlsHandshakes.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.base.RoundTrip(req)
+}
+
+// Stats returns the counts since t was created.
+func (t *Transport) Stats() TransportStats {
+	if t == nil {
+		return TransportStats{}
+	}
+	return TransportStats{
+		Requests:      t.requests.Load(),
+		NewConns:      t.newConns.Load(),
+		ReusedConns:   t.reusedConns.Load(),
+		TLSHandshakes: t.tlsHandshakes.Load(),
+	}
+}
+
+// CloseIdleConnections closes the pooled connections that are not in use.
+func (t *Transport) CloseIdleConnections() {
+	if t != nil {
+		t.base.CloseIdleConnections()
+	}
+}
+
+// Warm opens a connection to the host of rawURL through t. See Warm.
+func (t *Transport) Warm(ctx context.Context, rawURL string) error {
+	return Warm(ctx, t.Client(), rawURL)
+}
+
+// Warmer is implemented by streamers that can open a connection to their
+// provider ahead of the first request.
+type Warmer interface {
+	Warm(ctx context.Context) error
+}
+
+// Warm opens a connection to the host of rawURL with client, completing the
+// TLS handshake, and leaves it in the client's pool for the requests that
+// follow. It sends a HEAD request for the root of the host; any response,
+// whatever its status, counts as success.
+func Warm(ctx context.Context, client *http.Client, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return errors.New("llmstreamer: warm: URL has no host")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// Redirects could lead to other hosts, so the first response is kept.
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	// Draining the body lets the connection go back to the pool.
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
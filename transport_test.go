@@ -0,0 +1,76 @@
+package llmstreamer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTLSTransport(t *testing.T, srv *httptest.Server, cfg TransportConfig) *Transport {
+	t.Helper()
+	cfg.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	tr := NewTransport(cfg)
+	t.Cleanup(tr.CloseIdleConnections)
+	return tr
+}
+
+func TestTransport_WarmAndReuse(t *testing.T) {
+	for _, http2 := range []bool{false, true} {
+		var heads int
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				heads++
+				http.Redirect(w, r, "https://example.com/", http.StatusFound)
+				return
+			}
+			io.WriteString(w, "ok")
+		}))
+		srv.EnableHTTP2 = http2
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+
+		tr := newTLSTransport(t, srv, TransportConfig{DisableHTTP2: !http2})
+		if err := tr.Warm(context.Background(), srv.URL+"/v1/messages"); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			resp, err := tr.Client().Post(srv.URL+"/v1/messages", "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if got := resp.ProtoMajor == 2; got != http2 {
+				t.Fatalf("HTTP/2 = %v, want %v", got, http2)
+			}
+		}
+
+		want := TransportStats{Requests: 4, NewConns: 1, ReusedConns: 3, TLSHandshakes: 1}
+		if got := tr.Stats(); got != want || heads != 1 {
+			t.Fatalf("http2 %v: stats %+v, %d HEAD requests", http2, got, heads)
+		}
+		if r := want.ReuseRatio(); r != 0.75 {
+			t.Fatalf("ReuseRatio() = %v", r)
+		}
+	}
+}
+
+func TestTransport_Nil(t *testing.T) {
+	var tr *Transport
+	if tr.Client() != http.DefaultClient {
+		t.Fatal("nil Transport does not use http.DefaultClient")
+	}
+	if tr.Stats() != (TransportStats{}) {
+		t.Fatal("nil Transport reports stats")
+	}
+	tr.CloseIdleConnections()
+}
+
+func TestWarm_NoHost(t *testing.T) {
+	if err := Warm(context.Background(), nil, "/v1/messages"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,31 @@
+package llmstreamer
+
+// Usage reports the tokens billed for a request. InputTokens excludes tokens
+// read from or written to the prompt cache, which are counted separately, so
+// the four counts add up to the total input and output.
+type Usage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+
+	// Cost is the price of the request in USD, or 0 when the model has no
+	// entry in the provider's pricing table.
+	Cost float64
+}
+
+// Price lists the USD cost per million tokens of each kind.
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+}
+
+func (p Price) Cost(u Usage) float64 {
+	total := float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheReadTokens)*p.CacheRead +
+		float64(u.CacheWriteTokens)*p.CacheWrite
+	return total / 1_000_000
+}
@@ -0,0 +1,20 @@
+package llmstreamer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriceCost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	u := Usage{InputTokens: 1000, OutputTokens: 500, CacheReadTokens: 10000, CacheWriteTokens: 2000}
+
+	want := (1000*3 + 500*15 + 10000*0.3 + 2000*3.75) / 1e6
+	if got := p.Cost(u); math.Abs(got-want) > 1e-12 {
+		t.Fatalf("expected cost %v, got %v", want, got)
+	}
+
+	if got := (Price{}).Cost(u); got != 0 {
+		t.Fatalf("expected zero cost without prices, got %v", got)
+	}
+}
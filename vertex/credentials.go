@@ -0,0 +1,295 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+const (
+	DefaultTokenURL = "https://oauth2.googleapis.com/token"
+	DefaultScope    = "https://www.googleapis.com/auth/cloud-platform"
+
+	// tokenLifetime is the lifetime requested for minted tokens, the maximum
+	// Google allows.
+	tokenLifetime = time.Hour
+	// refreshMargin is how long before expiry a cached token is replaced, so
+	// a token does not expire while a request is in flight.
+	refreshMargin = time.Minute
+)
+
+// TokenSource supplies the OAuth access token sent with each request.
+// Implementations should cache tokens until they expire.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a fixed access token, for example one printed by
+// "gcloud auth print-access-token".
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	if t == "" {
+		return "", errors.New("vertex: empty access token")
+	}
+	return string(t), nil
+}
+
+// ServiceAccount mints access tokens from a service-account key using the
+// OAuth 2.0 JWT bearer grant. Tokens are cached and refreshed shortly before
+// they expire. A ServiceAccount is safe for concurrent use.
+type ServiceAccount struct {
+	Email        string
+	PrivateKey   *rsa.PrivateKey
+	PrivateKeyID string
+	// ProjectID is the project the key belongs to, if known.
+	ProjectID string
+
+	// TokenURL is the token endpoint. Empty means DefaultTokenURL.
+	TokenURL string
+	// Scopes requested for the token. Empty means DefaultScope.
+	Scopes []string
+	// HTTPClient sends the token requests. Nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+
+	// now is the clock used for JWT claims and expiry, replaced in tests.
+	now func() time.Time
+}
+
+var _ TokenSource = (*ServiceAccount)(nil)
+
+// serviceAccountKey is the JSON key file downloaded from the Cloud console.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ServiceAccountFromJSON parses a service-account JSON key.
+func ServiceAccountFromJSON(data []byte) (*ServiceAccount, error) {
+	var k serviceAccountKey
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("vertex: parse service account key: %w", err)
+	}
+	if k.Type != "service_account" {
+		return nil, fmt.Errorf("vertex: unsupported credentials type %q", k.Type)
+	}
+	if k.ClientEmail == "" {
+		return nil, errors.New("vertex: service account key has no client_email")
+	}
+
+	key, err := parsePrivateKey([]byte(k.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceAccount{
+		Email:        k.ClientEmail,
+		PrivateKey:   key,
+		PrivateKeyID: k.PrivateKeyID,
+		ProjectID:    k.ProjectID,
+		TokenURL:     k.TokenURI,
+	}, nil
+}
+
+// ServiceAccountFromFile reads a service-account JSON key from path.
+func ServiceAccountFromFile(path string) (*ServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vertex: %w", err)
+	}
+	return ServiceAccountFromJSON(data)
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("vertex: private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("vertex: private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("vertex: parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// Token returns the cached access token, minting a new one if there is none
+// or it is about to expire.
+func (sa *ServiceAccount) Token(ctx context.Context) (string, error) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	now := sa.clock()
+	if sa.token != "" && now.Add(refreshMargin).Before(sa.expiry) {
+		return sa.token, nil
+	}
+
+	token, expiresIn, err := sa.fetch(ctx, now)
+	if err != nil {
+		return "", fmt.Errorf("vertex: fetch access token: %w", err)
+	}
+	sa.token = token
+	sa.expiry = now.Add(expiresIn)
+	return token, nil
+}
+
+func (sa *ServiceAccount) clock() time.Time {
+	if sa.now != nil {
+		return sa.now()
+	}
+	return time.Now()
+}
+
+func (sa *ServiceAccount) tokenURL() string {
+	if sa.TokenURL != "" {
+		return sa.TokenURL
+	}
+	return DefaultTokenURL
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (sa *ServiceAccount) fetch(ctx context.Context, now time.Time) (string, time.Duration, error) {
+	assertion, err := sa.signJWT(now)
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.tokenURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := sa.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var tr tokenResponse
+	jsonErr := json.Unmarshal(body, &tr)
+
+	if resp.StatusCode != http.StatusOK {
+		e := &llmstreamer.APIError{
+			Provider:   "vertex",
+			StatusCode: resp.StatusCode,
+			RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+		}
+		if jsonErr != nil || tr.Error == "" {
+			e.Body = string(body)
+		} else {
+			e.Type = tr.Error
+			e.Message = tr.ErrorDescription
+		}
+		return "", 0, e
+	}
+	if jsonErr != nil {
+		return "", 0, fmt.Errorf("failed to parse JSON: %w", jsonErr)
+	}
+	if tr.AccessToken == "" {
+		return "", 0, errors.New("token response has no access_token")
+	}
+
+	expiresIn := time.Duration(tr.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = tokenLifetime
+	}
+	return tr.AccessToken, expiresIn, nil
+}
+
+// signJWT builds the RS256-signed assertion for the JWT bearer grant.
+func (sa *ServiceAccount) signJWT(now time.Time) (string, error) {
+	if sa.PrivateKey == nil {
+		return "", errors.New("service account has no private key")
+	}
+
+	scopes := sa.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{DefaultScope}
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if sa.PrivateKeyID != "" {
+		header["kid"] = sa.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   sa.Email,
+		"scope": strings.Join(scopes, " "),
+		"aud":   sa.tokenURL(),
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	}
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, sa.PrivateKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,50 @@
+package vertex
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+)
+
+// ErrorBody covers both error formats Vertex returns: Anthropic's
+// {"type":"error","error":{"type":...}} from the model, and Google's
+// {"error":{"code":...,"status":...}} from the platform, for example for
+// authentication and quota errors.
+type ErrorBody struct {
+	Error *ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *llmstreamer.APIError {
+	e := &llmstreamer.APIError{
+		Provider:   "vertex",
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("request-id"),
+		RetryAfter: llmstreamer.ParseRetryAfter(resp.Header),
+	}
+
+	var eb ErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil || eb.Error == nil {
+		// Google APIs sometimes wrap the error in a one-element array.
+		var list []ErrorBody
+		if err := json.Unmarshal(body, &list); err != nil || len(list) == 0 || list[0].Error == nil {
+			e.Body = string(body)
+			return e
+		}
+		eb = list[0]
+	}
+
+	e.Type = eb.Error.Type
+	if e.Type == "" {
+		e.Type = eb.Error.Status
+	}
+	e.Message = eb.Error.Message
+	return e
+}
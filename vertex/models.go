@@ -0,0 +1,15 @@
+package vertex
+
+// Model is a Claude model ID in the Vertex AI Model Garden, where the version
+// follows an "@".
+type Model string
+
+const (
+	ModelClaude35SonnetV2 Model = "claude-3-5-sonnet-v2@20241022"
+	ModelClaude35Sonnet   Model = "claude-3-5-sonnet@20240620"
+	ModelClaude35Haiku    Model = "claude-3-5-haiku@20241022"
+
+	ModelClaude3Opus   Model = "claude-3-opus@20240229"
+	ModelClaude3Sonnet Model = "claude-3-sonnet@20240229"
+	ModelClaude3Haiku  Model = "claude-3-haiku@20240307"
+)
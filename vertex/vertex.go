@@ -0,0 +1,257 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/alparslanyilmaaz/llmstreamer"
+	"github.com/alparslanyilmaaz/llmstreamer/anthropic"
+	"github.com/alparslanyilmaaz/llmstreamer/sse"
+)
+
+const anthropicVersion = "vertex-2023-10-16"
+
+// VertexStreamer streams Claude responses from Google Cloud Vertex AI. Requests
+// use the Anthropic Messages API format and the streamed events are handled by
+// the anthropic package.
+type VertexStreamer struct {
+	ProjectID string
+	// Region is the Vertex AI location, such as "us-east5", or "global".
+	Region string
+	Model  Model
+	// Token supplies the OAuth access tokens, usually a *ServiceAccount.
+	Token TokenSource
+	// Retry controls retries of failed requests. Nil means a single attempt.
+	Retry *llmstreamer.RetryPolicy
+	// Timeouts bounds each attempt. Nil means no limits beyond the context.
+	Timeouts *llmstreamer.Timeouts
+
+	// HTTPClient sends the requests. Nil means the client of Transport; set
+	// a client with a custom Transport to supply your own http.RoundTripper.
+	HTTPClient *http.Client
+	// Transport, if set, sends the requests made without an HTTPClient over
+	// its tuned connection pool. Nil means http.DefaultClient.
+	Transport *llmstreamer.Transport
+	// BaseURL replaces the regional aiplatform endpoint, for Private Service
+	// Connect endpoints and local stand-ins. It is the URL up to and
+	// including /v1.
+	BaseURL string
+	// Header is added to every request. It overrides the headers set by the
+	// streamer.
+	Header http.Header
+}
+
+func New(projectID, region string, model Model, token TokenSource) *VertexStreamer {
+	return &VertexStreamer{
+		ProjectID: projectID,
+		Region:    region,
+		Model:     model,
+		Token:     token,
+	}
+}
+
+var (
+	_ llmstreamer.Streamer = (*VertexStreamer)(nil)
+	_ llmstreamer.Warmer   = (*VertexStreamer)(nil)
+)
+
+// The registered factory takes the project from GOOGLE_CLOUD_PROJECT and the
+// region from CLOUD_ML_REGION. Config.APIKey, if set, is used as a static
+// access token; otherwise the service-account key named by
+// GOOGLE_APPLICATION_CREDENTIALS is used.
+func init() {
+	llmstreamer.Register("vertex", func(cfg llmstreamer.Config) (llmstreamer.Streamer, error) {
+		var token TokenSource = StaticToken(cfg.APIKey)
+		projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+
+		if cfg.APIKey == "" {
+			path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+			if path == "" {
+				return nil, errors.New("vertex: set Config.APIKey or GOOGLE_APPLICATION_CREDENTIALS")
+			}
+			sa, err := ServiceAccountFromFile(path)
+			if err != nil {
+				return nil, err
+			}
+			sa.HTTPClient = cfg.HTTPClient
+			if projectID == "" {
+				projectID = sa.ProjectID
+			}
+			token = sa
+		}
+
+		region := os.Getenv("CLOUD_ML_REGION")
+		if projectID == "" || region == "" {
+			return nil, errors.New("vertex: GOOGLE_CLOUD_PROJECT and CLOUD_ML_REGION are required")
+		}
+
+		s := New(projectID, region, Model(cfg.Model), token)
+		s.Retry = cfg.Retry
+		s.Timeouts = cfg.Timeouts
+		s.HTTPClient = cfg.HTTPClient
+		s.Transport = cfg.Transport
+		s.BaseURL = cfg.BaseURL
+		s.Header = cfg.Header
+		return s, nil
+	})
+}
+
+func (s *VertexStreamer) StreamChat(
+	ctx context.Context,
+	messages []llmstreamer.Message,
+	cb *llmstreamer.StreamCallbacks,
+	opts ...llmstreamer.Option,
+) {
+	ctx, cb = llmstreamer.TrackStats(ctx, cb)
+
+	if s.Token == nil {
+		cb.EmitError(errors.New("vertex: no token source"))
+		return
+	}
+	if s.ProjectID == "" || s.Region == "" {
+		cb.EmitError(errors.New("vertex: project and region are required"))
+		return
+	}
+
+	model := s.Model
+	if model == "" {
+		model = ModelClaude35SonnetV2
+	}
+
+	o := llmstreamer.NewOptions(opts...)
+	payload, err := anthropic.NewRequestBody(anthropic.Model(model), messages, o)
+	if err != nil {
+		cb.EmitError(err)
+		return
+	}
+	// Vertex takes the model from the URL and the version from the body.
+	payload.Model = ""
+	payload.AnthropicVersion = anthropicVersion
+
+	err = s.Retry.Do(ctx, cb, func(cb *llmstreamer.StreamCallbacks) error {
+		return s.Timeouts.Do(ctx, cb, func(ctx context.Context, cb *llmstreamer.StreamCallbacks) error {
+			return s.stream(ctx, model, payload, anthropic.ResponseFormatCallbacks(o, cb))
+		})
+	})
+	if err != nil {
+		cb.EmitError(err)
+	}
+}
+
+// Stream starts a response and returns it as a *llmstreamer.Stream of events.
+// See llmstreamer.NewStream.
+func (s *VertexStreamer) Stream(ctx context.Context, messages []llmstreamer.Message, opts ...llmstreamer.Option) (*llmstreamer.Stream, error) {
+	return llmstreamer.NewStream(ctx, s, messages, opts...)
+}
+
+// Warm opens a connection to the API host ahead of the first request. See
+// llmstreamer.Warm.
+func (s *VertexStreamer) Warm(ctx context.Context) error {
+	return llmstreamer.Warm(ctx, s.client(), s.endpoint(s.Model))
+}
+
+func (s *VertexStreamer) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return s.Transport.Client()
+}
+
+func (s *VertexStreamer) stream(ctx context.Context, model Model, payload anthropic.RequestBody, cb *llmstreamer.StreamCallbacks) error {
+	client, req, err := s.prepareRequest(ctx, model, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	resp.Body = llmstreamer.WatchBody(ctx, resp.Body)
+	return processStream(resp, cb)
+}
+
+func (s *VertexStreamer) prepareRequest(ctx context.Context, model Model, payload anthropic.RequestBody) (*http.Client, *http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := s.Token.Token(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(model), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	llmstreamer.SetHeaders(req.Header, s.Header)
+
+	client := s.client()
+
+	return client, req, nil
+}
+
+func (s *VertexStreamer) endpoint(model Model) string {
+	base := strings.TrimSuffix(s.BaseURL, "/")
+	if base == "" {
+		host := s.Region + "-aiplatform.googleapis.com"
+		if s.Region == "global" {
+			host = "aiplatform.googleapis.com"
+		}
+		base = "https://" + host + "/v1"
+	}
+	return base + "/projects/" + s.ProjectID + "/locations/" + s.Region +
+		"/publishers/anthropic/models/" + string(model) + ":streamRawPredict"
+}
+
+// processStream reads the SSE response, which carries the same events as the
+// Messages API, and hands them to anthropic.ProcessEvents.
+func processStream(resp *http.Response, cb *llmstreamer.StreamCallbacks) error {
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		apiErr := newAPIError(resp, b)
+		if err != nil {
+			apiErr.Message = fmt.Sprintf("read body failed: %v", err)
+		}
+		return apiErr
+	}
+
+	dec := sse.NewDecoder(resp.Body)
+	dec.DispatchAtEOF = true
+	defer dec.Release()
+	next := func() ([]byte, error) {
+		ev, err := dec.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("read failed: %w", err)
+		}
+		return ev.Data, nil
+	}
+
+	err := anthropic.ProcessEvents(next, cb)
+
+	var apiErr *llmstreamer.APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Provider = "vertex"
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = resp.Header.Get("request-id")
+		}
+	}
+	return err
+}